@@ -1,20 +1,185 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/ahmetb/go-httpbin"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var (
-	host = flag.String("host", ":8080", "<host:port>")
+	host         = flag.String("host", ":8080", "<host:port>")
+	headers      = headerFlag{}
+	responseTime = flag.Bool("response-time", false, "add X-Response-Time header (in ms) to responses")
+	h2cEnabled   = flag.Bool("h2c", false, "serve HTTP/2 over cleartext (h2c) in addition to HTTP/1.x")
+	tlsCert      = flag.String("tls-cert", "", "serve HTTPS with this certificate file (requires -tls-key)")
+	tlsKey       = flag.String("tls-key", "", "private key file of -tls-cert")
+	alpn         = flag.String("alpn", "h2,http/1.1", "comma-separated ALPN protocols offered over TLS, e.g. h2 to refuse HTTP/1.1-only clients")
+	compress     = flag.Bool("compress", false, "gzip-encode responses for clients that accept it")
+	jsonKeys     = flag.String("json-keys", "default", "spelling of JSON response keys: default, snake or camel")
+	debugStats   = flag.Bool("debug-stats", false, "enable /debug/stats reporting goroutines and memory usage")
+	readTimeout  = flag.Duration("read-timeout", 0, "maximum duration for reading a request, including its body (0 for no timeout)")
+	writeTimeout = flag.Duration("write-timeout", 0, "maximum duration for writing a response, which cuts streaming endpoints short (0 for no timeout)")
+	idleTimeout  = flag.Duration("idle-timeout", 0, "maximum duration to keep an idle connection open (0 to use -read-timeout)")
+	acceptDelay  = flag.Duration("accept-delay", 0, "sleep in Accept before returning each new connection, e.g. 500ms")
+	delayLimit   = flag.Int("delay-concurrency", 0, "respond to /delay with 503 while this many of its requests are in flight (0 for no limit)")
+	recordMax    = flag.Int("record-requests", 0, "record the last n requests for /requests (0 to disable)")
+	resourceTTL  = flag.Duration("resource-ttl", httpbin.ResourceTTL, "expire resources created through /resources after this long, e.g. 10m (0 to keep them until evicted)")
 )
 
+func init() {
+	flag.Var(headers, "header", "default response header as <key:value>, can be repeated")
+}
+
+// headerFlag collects repeated <key:value> flags into an http.Header.
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	var s []string
+	for k, v := range h {
+		for _, vv := range v {
+			s = append(s, k+":"+vv)
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+func (h headerFlag) Set(v string) error {
+	kv := strings.SplitN(v, ":", 2)
+	if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+		return fmt.Errorf("header %q is not in <key:value> format", v)
+	}
+	http.Header(h).Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	return nil
+}
+
+// delayListener sleeps for delay in Accept before returning each connection,
+// so that clients see slow connection establishment. Connections are accepted
+// one at a time, so those arriving together are delayed in turn.
+type delayListener struct {
+	net.Listener
+	delay time.Duration
+}
+
+func (l delayListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	time.Sleep(l.delay)
+	return c, nil
+}
+
 func main() {
 	flag.Parse()
 
+	var opts []httpbin.Option
+	if len(headers) > 0 {
+		opts = append(opts, httpbin.WithDefaultHeaders(http.Header(headers)))
+	}
+	if *responseTime {
+		opts = append(opts, httpbin.WithResponseTime())
+	}
+	if *compress {
+		opts = append(opts, httpbin.WithCompression())
+	}
+	if *debugStats {
+		opts = append(opts, httpbin.WithDebugStats())
+	}
+	if *delayLimit > 0 {
+		opts = append(opts, httpbin.WithDelayConcurrencyLimit(*delayLimit))
+	}
+	if *recordMax > 0 {
+		opts = append(opts, httpbin.WithRequestRecording(*recordMax))
+	}
+	opts = append(opts, httpbin.WithResourceTTL(*resourceTTL))
+	switch *jsonKeys {
+	case "default":
+	case "snake":
+		opts = append(opts, httpbin.WithJSONKeyStyle(httpbin.JSONKeySnake))
+	case "camel":
+		opts = append(opts, httpbin.WithJSONKeyStyle(httpbin.JSONKeyCamel))
+	default:
+		log.Fatalf("unknown -json-keys style %q", *jsonKeys)
+	}
+
+	var h http.Handler = httpbin.GetMux(opts...)
+	if *h2cEnabled {
+		h = h2c.NewHandler(h, &http2.Server{})
+	}
+
+	srv := &http.Server{
+		Addr:         *host,
+		Handler:      h,
+		ConnContext:  httpbin.ConnContext,
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
+	}
+
+	ln, err := net.Listen("tcp", *host)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *acceptDelay > 0 {
+		ln = delayListener{ln, *acceptDelay}
+	}
+	if *tlsCert != "" || *tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// srv.ServeTLS would add http/1.1 to the offered protocols, so
+		// terminate TLS in the listener to offer exactly the -alpn ones.
+		// The raw bytes of TLS connections are not recorded, as they
+		// would be encrypted.
+		protos := splitList(*alpn)
+		ln = tls.NewListener(ln, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   protos,
+			// crypto/tls lets clients offering http/1.1 through without
+			// a protocol when none matches, fail their handshake instead.
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				if len(hello.SupportedProtos) > 0 && !overlaps(protos, hello.SupportedProtos) {
+					return nil, fmt.Errorf("client offered ALPN protocols %q, none of %q", hello.SupportedProtos, protos)
+				}
+				return nil, nil
+			},
+		})
+		log.Printf("httpbin listening on %s (TLS)", *host)
+		log.Fatal(srv.Serve(ln))
+	}
 	log.Printf("httpbin listening on %s", *host)
-	log.Fatal(http.ListenAndServe(*host, httpbin.GetMux()))
+	log.Fatal(srv.Serve(httpbin.Listener(ln)))
+}
+
+// overlaps reports whether a and b have an element in common.
+func overlaps(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitList returns the non-empty elements of the comma-separated list s.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
 }
@@ -1,5 +1,7 @@
 package httpbin
 
+import "strings"
+
 const (
 	htmlData = `<!DOCTYPE html>
 <html>
@@ -40,3 +42,27 @@ const (
 
 </slideshow>`
 )
+
+// sampleSlideshow is the slideshow of xmlData, served by /json, /msgpack and
+// /yaml.
+var sampleSlideshow = slideshowResponse{Slideshow: slideshow{
+	Title:  "Sample Slide Show",
+	Date:   "Date of publication",
+	Author: "Yours Truly",
+	Slides: []slide{
+		{Type: "all", Title: "Wake up to WonderWidgets!"},
+		{Type: "all", Title: "Overview", Items: []string{
+			"Why <em>WonderWidgets</em> are great",
+			"",
+			"Who <em>buys</em> WonderWidgets",
+		}},
+	},
+}}
+
+// words is the dictionary used to generate text for /words endpoint.
+var words = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do
+eiusmod tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam
+quis nostrud exercitation ullamco laboris nisi aliquip ex ea commodo consequat
+duis aute irure in reprehenderit voluptate velit esse cillum fugiat nulla
+pariatur excepteur sint occaecat cupidatat non proident sunt culpa qui officia
+deserunt mollit anim id est laborum`)
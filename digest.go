@@ -0,0 +1,217 @@
+package httpbin
+
+import (
+	"container/list"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+const (
+	digestRealm = "go-httpbin"
+
+	// digestNonceTTL is how long a nonce issued by /digest-auth is accepted.
+	digestNonceTTL = 5 * time.Minute
+
+	// digestNoncesMax bounds the nonces tracked at once, the oldest ones are
+	// forgotten beyond it.
+	digestNoncesMax = 10000
+)
+
+// digestAuth issues the nonces of /digest-auth challenges and remembers the
+// highest nonce count used with each, so that replayed responses are
+// rejected.
+type digestAuth struct {
+	mu     sync.Mutex
+	nonces map[string]*list.Element
+	order  *list.List // *digestNonce values, oldest first
+}
+
+type digestNonce struct {
+	nonce   string
+	expires time.Time
+	nc      uint64 // highest nonce count seen
+}
+
+func newDigestAuth() *digestAuth {
+	return &digestAuth{nonces: make(map[string]*list.Element), order: list.New()}
+}
+
+// remove forgets the nonce in e. d.mu must be held.
+func (d *digestAuth) remove(e *list.Element) {
+	delete(d.nonces, d.order.Remove(e).(*digestNonce).nonce)
+}
+
+// newNonce returns a fresh nonce, dropping expired ones and the oldest ones
+// beyond digestNoncesMax.
+func (d *digestAuth) newNonce() string {
+	var b [16]byte
+	rand.Read(b[:])
+	nonce := hex.EncodeToString(b[:])
+
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// nonces expire in issue order, so only the oldest ones need to be
+	// looked at
+	for e := d.order.Front(); e != nil; e = d.order.Front() {
+		if !now.After(e.Value.(*digestNonce).expires) && d.order.Len() < digestNoncesMax {
+			break
+		}
+		d.remove(e)
+	}
+	d.nonces[nonce] = d.order.PushBack(&digestNonce{nonce: nonce, expires: now.Add(digestNonceTTL)})
+	return nonce
+}
+
+// use records that nonce was used with the nonce count nc. It reports false
+// if the nonce is unknown or expired, which makes it stale, or if nc was
+// already used, which makes the response a replay.
+func (d *digestAuth) use(nonce string, nc uint64) (stale, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, found := d.nonces[nonce]
+	if !found {
+		return true, false
+	}
+	v := e.Value.(*digestNonce)
+	if time.Now().After(v.expires) {
+		d.remove(e)
+		return true, false
+	}
+	if nc <= v.nc {
+		return false, false
+	}
+	v.nc = nc
+	return false, true
+}
+
+// handler challenges with HTTP Digest authentication (RFC 7616) for the user
+// and password in the path, using MD5 and the quality of protection in the
+// path, "auth" or "auth-int". Each nonce count of a nonce is only accepted
+// once, and nonces expire after digestNonceTTL.
+func (d *digestAuth) handler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	qop, user, pass := vars["qop"], vars["u"], vars["p"]
+
+	var body []byte
+	if qop == "auth-int" {
+		var err error
+		if body, err = parseData(r); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+	}
+
+	params, ok := parseDigestAuthorization(r.Header.Get("Authorization"))
+	stale := false
+	if ok {
+		ok, stale = d.verify(r, params, qop, user, pass, body)
+	}
+	if !ok {
+		challenge := fmt.Sprintf(`Digest realm="%s", qop="%s", nonce="%s", algorithm=MD5`, digestRealm, qop, d.newNonce())
+		if stale {
+			challenge += ", stale=true"
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err := writeJSON(w, basicAuthResponse{Authenticated: true, User: user}); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// verify checks the digest response in params and reports whether it is
+// valid, and otherwise whether it is only wrong for using a stale nonce.
+func (d *digestAuth) verify(r *http.Request, params map[string]string, qop, user, pass string, body []byte) (ok, stale bool) {
+	if params["username"] != user || params["realm"] != digestRealm || params["uri"] != r.RequestURI ||
+		params["qop"] != qop || params["cnonce"] == "" {
+		return false, false
+	}
+	if alg := params["algorithm"]; alg != "" && !strings.EqualFold(alg, "MD5") {
+		return false, false
+	}
+	nc, err := strconv.ParseUint(params["nc"], 16, 64)
+	if err != nil {
+		return false, false
+	}
+
+	ha1 := md5Hex(user + ":" + digestRealm + ":" + pass)
+	ha2 := md5Hex(r.Method + ":" + params["uri"])
+	if qop == "auth-int" {
+		ha2 = md5Hex(r.Method + ":" + params["uri"] + ":" + md5Hex(string(body)))
+	}
+	want := md5Hex(strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], qop, ha2}, ":"))
+	if subtle.ConstantTimeCompare([]byte(want), []byte(strings.ToLower(params["response"]))) != 1 {
+		return false, false
+	}
+	// only consume the nonce count once the response is known to be right
+	stale, ok = d.use(params["nonce"], nc)
+	return ok, stale
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseDigestAuthorization returns the parameters of an Authorization header
+// using the Digest scheme, unquoting their values. It reports false if the
+// header uses another scheme or is malformed.
+func parseDigestAuthorization(h string) (map[string]string, bool) {
+	i := strings.IndexByte(h, ' ')
+	if i < 0 || !strings.EqualFold(h[:i], "digest") {
+		return nil, false
+	}
+	params := make(map[string]string)
+	s := strings.TrimSpace(h[i+1:])
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, false
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			var b strings.Builder
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' && end+1 < len(s) {
+					end++
+				}
+				b.WriteByte(s[end])
+			}
+			if end == len(s) {
+				return nil, false // unterminated quoted string
+			}
+			value, s = b.String(), s[end+1:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+		params[key] = value
+		s = strings.TrimLeft(s, " ")
+		if s != "" {
+			if s[0] != ',' {
+				return nil, false
+			}
+			s = strings.TrimLeft(s[1:], " ")
+		}
+	}
+	return params, true
+}
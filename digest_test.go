@@ -0,0 +1,54 @@
+package httpbin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDigestAuthorization(t *testing.T) {
+	params, ok := parseDigestAuthorization(`digest username="Mufasa", realm="a, \"b\"",nc=00000001 , qop=auth`)
+	require.True(t, ok)
+	require.Equal(t, map[string]string{
+		"username": "Mufasa",
+		"realm":    `a, "b"`,
+		"nc":       "00000001",
+		"qop":      "auth",
+	}, params)
+
+	for _, h := range []string{"", "Basic dXNlcg==", `Digest username="x`, `Digest username="x" realm="y"`, `Digest =x`} {
+		_, ok := parseDigestAuthorization(h)
+		require.False(t, ok, h)
+	}
+}
+
+func TestDigestAuthNonces(t *testing.T) {
+	d := newDigestAuth()
+	first := d.newNonce()
+	second := d.newNonce()
+	for i := 2; i < digestNoncesMax; i++ {
+		d.newNonce()
+	}
+	_, ok := d.use(first, 1)
+	require.True(t, ok)
+
+	// only the oldest nonce is forgotten to make room
+	last := d.newNonce()
+	require.Len(t, d.nonces, digestNoncesMax)
+	stale, ok := d.use(first, 2)
+	require.True(t, stale)
+	require.False(t, ok)
+	for _, nonce := range []string{second, last} {
+		_, ok := d.use(nonce, 1)
+		require.True(t, ok, nonce)
+	}
+
+	// expired nonces are dropped when seen
+	d.nonces[second].Value.(*digestNonce).expires = time.Now().Add(-time.Second)
+	stale, ok = d.use(second, 2)
+	require.True(t, stale)
+	require.False(t, ok)
+	require.NotContains(t, d.nonces, second)
+	require.Equal(t, d.order.Len(), len(d.nonces))
+}
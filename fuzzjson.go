@@ -0,0 +1,146 @@
+package httpbin
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// fuzzDocument is served by /fuzz-json. Every payload matches this schema:
+// all keys are always present, only 'note' and 'value' may be null, arrays
+// may be empty but never null, and 'children' nest at most fuzzMaxDepth
+// levels deep.
+type fuzzDocument struct {
+	ID       int64      `json:"id"`
+	Name     string     `json:"name"`
+	Score    float64    `json:"score"`
+	Active   bool       `json:"active"`
+	Tags     []string   `json:"tags"`
+	Note     *string    `json:"note"`
+	Children []fuzzNode `json:"children"`
+}
+
+type fuzzNode struct {
+	Label    string     `json:"label"`
+	Value    *float64   `json:"value"`
+	Children []fuzzNode `json:"children"`
+}
+
+const (
+	fuzzMaxDepth   = 4
+	fuzzMaxItems   = 5
+	fuzzLongString = 4096 // minimum length of long strings
+)
+
+// fuzzStrings are edge case strings mixed into generated values.
+var fuzzStrings = []string{
+	"",
+	" ",
+	"\"quoted\" \\ back/slash",
+	"line\nbreak\ttab\r",
+	"\x00\x01\x1f",
+	"<script>&amp;</script>",
+	"naïve café",
+	"日本語のテキスト",
+	"مرحبا بالعالم",
+	"e\u0301\u0308",      // combining marks
+	"😀👍🏽👨\u200d👩\u200d👧", // emoji with modifiers and joiners
+	"\u00a0\u2028\u2029", // no-break space, line and paragraph separators
+	"\ufeffbom",
+}
+
+// fuzzInts and fuzzFloats are edge case numbers mixed into generated values.
+// Integers stay within the range exactly representable by a float64 so
+// JavaScript clients can parse them.
+var (
+	fuzzInts   = []int64{0, 1, -1, 1<<53 - 1, -(1<<53 - 1), math.MaxInt32, math.MinInt32}
+	fuzzFloats = []float64{0, -1.5, 0.1, 1e-300, 5e-324, 1.7976931348623157e308, -1e21, 123456789.123456789}
+)
+
+// fuzzGenerator produces fuzzDocuments from a pseudo-random source. About one
+// in five values is picked from the edge cases above.
+type fuzzGenerator struct {
+	rnd *rand.Rand
+}
+
+func newFuzzGenerator(seed int64) *fuzzGenerator {
+	return &fuzzGenerator{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (g *fuzzGenerator) edge() bool { return g.rnd.Intn(5) == 0 }
+
+func (g *fuzzGenerator) document() fuzzDocument {
+	d := fuzzDocument{
+		ID:       g.int(),
+		Name:     g.string(),
+		Score:    g.float(),
+		Active:   g.rnd.Intn(2) == 1,
+		Tags:     make([]string, g.count()),
+		Children: g.nodes(1),
+	}
+	for i := range d.Tags {
+		d.Tags[i] = g.string()
+	}
+	if g.rnd.Intn(3) > 0 {
+		s := g.string()
+		d.Note = &s
+	}
+	return d
+}
+
+func (g *fuzzGenerator) nodes(depth int) []fuzzNode {
+	if depth > fuzzMaxDepth {
+		return []fuzzNode{}
+	}
+	nodes := make([]fuzzNode, g.count())
+	for i := range nodes {
+		nodes[i] = fuzzNode{
+			Label:    g.string(),
+			Children: g.nodes(depth + 1),
+		}
+		if g.rnd.Intn(3) > 0 {
+			f := g.float()
+			nodes[i].Value = &f
+		}
+	}
+	return nodes
+}
+
+// count returns an array length, zero more often than not as nesting would
+// otherwise grow the payload exponentially.
+func (g *fuzzGenerator) count() int {
+	if g.rnd.Intn(2) == 0 {
+		return 0
+	}
+	return g.rnd.Intn(fuzzMaxItems) + 1
+}
+
+func (g *fuzzGenerator) int() int64 {
+	if g.edge() {
+		return fuzzInts[g.rnd.Intn(len(fuzzInts))]
+	}
+	return g.rnd.Int63n(2000000) - 1000000
+}
+
+func (g *fuzzGenerator) float() float64 {
+	if g.edge() {
+		return fuzzFloats[g.rnd.Intn(len(fuzzFloats))]
+	}
+	return g.rnd.NormFloat64() * 1000
+}
+
+func (g *fuzzGenerator) string() string {
+	if g.edge() {
+		if g.rnd.Intn(len(fuzzStrings)+1) == 0 {
+			w := words[g.rnd.Intn(len(words))] + " "
+			return strings.Repeat(w, fuzzLongString/len(w)+1)
+		}
+		return fuzzStrings[g.rnd.Intn(len(fuzzStrings))]
+	}
+	n := g.rnd.Intn(4) + 1
+	ws := make([]string, n)
+	for i := range ws {
+		ws[i] = words[g.rnd.Intn(len(words))]
+	}
+	return strings.Join(ws, " ")
+}
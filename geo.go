@@ -0,0 +1,55 @@
+package httpbin
+
+import "net"
+
+type geoPoint struct {
+	lat, long float64
+}
+
+// geoCountries holds a canned location, roughly the capital, of each country
+// known to /geo by its ISO 3166-1 alpha-2 code.
+var geoCountries = map[string]geoPoint{
+	"AU": {-35.28, 149.13},
+	"BR": {-15.79, -47.88},
+	"CA": {45.42, -75.70},
+	"CN": {39.90, 116.40},
+	"DE": {52.52, 13.40},
+	"ES": {40.42, -3.70},
+	"FR": {48.86, 2.35},
+	"GB": {51.51, -0.13},
+	"IN": {28.61, 77.21},
+	"IT": {41.90, 12.50},
+	"JP": {35.68, 139.69},
+	"KR": {37.57, 126.98},
+	"MX": {19.43, -99.13},
+	"NL": {52.37, 4.90},
+	"SE": {59.33, 18.07},
+	"TR": {39.93, 32.86},
+	"US": {38.90, -77.04},
+	"ZA": {-25.75, 28.19},
+}
+
+// geoNetworks is a synthetic IP-to-country table over the address blocks
+// reserved for documentation (RFC 5737 and RFC 3849), so that clients can
+// exercise the IP fallback of /geo deterministically.
+var geoNetworks = []struct {
+	cidr    string
+	country string
+}{
+	{"192.0.2.0/24", "US"},
+	{"198.51.100.0/24", "DE"},
+	{"203.0.113.0/24", "JP"},
+	{"2001:db8::/32", "GB"},
+}
+
+// geoCountryForIP returns the country of ip in geoNetworks, or "" if it is
+// not listed.
+func geoCountryForIP(ip net.IP) string {
+	for _, n := range geoNetworks {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		if err == nil && ipnet.Contains(ip) {
+			return n.country
+		}
+	}
+	return ""
+}
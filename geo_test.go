@@ -0,0 +1,23 @@
+package httpbin
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeoCountryForIP(t *testing.T) {
+	for ip, country := range map[string]string{
+		"192.0.2.10":   "US",
+		"198.51.100.1": "DE",
+		"2001:db8::1":  "GB",
+		"127.0.0.1":    "",
+	} {
+		require.Equal(t, country, geoCountryForIP(net.ParseIP(ip)), ip)
+	}
+	for _, n := range geoNetworks {
+		_, ok := geoCountries[n.country]
+		require.True(t, ok, "no location for %s", n.country)
+	}
+}
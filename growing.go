@@ -0,0 +1,126 @@
+package httpbin
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	growingCookie = "growing_session"
+
+	// growingSessionsMax bounds the sessions tracked at once, the least
+	// recently used ones are forgotten beyond it.
+	growingSessionsMax = 10000
+)
+
+// growingPattern is repeated to make up the /growing resource, so that each
+// response is a prefix of the next one.
+var growingPattern = []byte("0123456789abcdefghijklmnopqrstuvwxyz\n")
+
+// grower tracks how many times each session fetched /growing.
+type grower struct {
+	mu       sync.Mutex
+	sessions map[string]*list.Element
+	order    *list.List // *growingSession values, least recently used first
+}
+
+type growingSession struct {
+	id    string
+	calls int
+}
+
+func newGrower() *grower {
+	return &grower{sessions: make(map[string]*list.Element), order: list.New()}
+}
+
+// remove forgets the session in e. g.mu must be held.
+func (g *grower) remove(e *list.Element) {
+	delete(g.sessions, g.order.Remove(e).(*growingSession).id)
+}
+
+// session returns the session of the request, from the 'session' query
+// parameter or the session cookie, and reports whether it is new.
+func (g *grower) session(r *http.Request) (string, bool) {
+	if v := r.URL.Query().Get("session"); v != "" {
+		return v, false
+	}
+	if c, err := r.Cookie(growingCookie); err == nil && c.Value != "" {
+		return c.Value, false
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:]), true
+}
+
+// growHandler returns a resource that grows by 'step' bytes (default 100, at
+// most BinaryChunkSize) on each GET in the same session, up to BytesMax. The
+// session is tracked with a cookie set on the first response, or given in the
+// 'session' query parameter by clients without cookie support.
+func (g *grower) growHandler(w http.ResponseWriter, r *http.Request) {
+	step := 100
+	if v := r.URL.Query().Get("step"); v != "" {
+		var err error
+		if step, err = strconv.Atoi(v); err != nil || step < 0 || step > BinaryChunkSize {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.Errorf("step must be between 0 and %d", BinaryChunkSize))
+			return
+		}
+	}
+	id, isNew := g.session(r)
+	if isNew {
+		http.SetCookie(w, &http.Cookie{Name: growingCookie, Value: id, Path: "/"})
+	}
+
+	g.mu.Lock()
+	e, ok := g.sessions[id]
+	if ok {
+		g.order.MoveToBack(e)
+	} else {
+		if g.order.Len() >= growingSessionsMax {
+			g.remove(g.order.Front())
+		}
+		e = g.order.PushBack(&growingSession{id: id})
+		g.sessions[id] = e
+	}
+	v := e.Value.(*growingSession)
+	v.calls++
+	calls := v.calls
+	g.mu.Unlock()
+
+	n := calls * step
+	if n > BytesMax || n < 0 {
+		n = BytesMax
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(n))
+	w.Header().Set("X-Growing-Calls", strconv.Itoa(calls))
+	for n > 0 {
+		b := growingPattern
+		if n < len(b) {
+			b = b[:n]
+		}
+		if _, err := w.Write(b); err != nil {
+			return // client went away
+		}
+		n -= len(b)
+	}
+}
+
+// resetHandler forgets the session of the request, so that its next GET
+// starts over from the first step.
+func (g *grower) resetHandler(w http.ResponseWriter, r *http.Request) {
+	id, isNew := g.session(r)
+	if !isNew {
+		g.mu.Lock()
+		if e, ok := g.sessions[id]; ok {
+			g.remove(e)
+		}
+		g.mu.Unlock()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
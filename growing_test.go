@@ -0,0 +1,33 @@
+package httpbin
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrowerSessions(t *testing.T) {
+	g := newGrower()
+	grow := func(session string) int {
+		w := httptest.NewRecorder()
+		g.growHandler(w, httptest.NewRequest("GET", "/growing?step=0&session="+session, nil))
+		calls, err := strconv.Atoi(w.Header().Get("X-Growing-Calls"))
+		require.Nil(t, err)
+		return calls
+	}
+
+	for i := 0; i < growingSessionsMax; i++ {
+		grow(strconv.Itoa(i))
+	}
+	require.Equal(t, 2, grow("0")) // now the most recently used
+
+	// only the least recently used session is forgotten to make room
+	require.Equal(t, 1, grow("new"))
+	require.Len(t, g.sessions, growingSessionsMax)
+	require.Equal(t, 1, grow("1"))
+	require.Equal(t, 3, grow("0"))
+	require.Equal(t, 2, grow(strconv.Itoa(growingSessionsMax-1)))
+	require.Equal(t, g.order.Len(), len(g.sessions))
+}
@@ -3,12 +3,21 @@
 package httpbin
 
 import (
+	"bufio"
+	"bytes"
 	"compress/flate"
 	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
@@ -16,15 +25,27 @@ import (
 	"io/ioutil"
 	"math"
 	"math/rand"
+	"mime/multipart"
 	"net"
 	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/andybalholm/brotli"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/language"
 )
 
 var (
@@ -35,47 +56,220 @@ var (
 	// DelayMax is the maximum execution time for /delay endpoint.
 	DelayMax = 10 * time.Second
 
+	// SlowMax is the maximum execution time for /slow endpoint.
+	SlowMax = 5 * time.Minute
+
+	// TaskMax is the maximum duration of the task simulated by /task endpoint.
+	TaskMax = 5 * time.Minute
+
 	// StreamInterval is the default interval between writing objects to the stream.
 	StreamInterval = 1 * time.Second
+
+	// MultipartMax is the maximum number of parts for /multipart endpoint.
+	MultipartMax = 100
+
+	// MaxBodySize is the maximum request body size accepted by the endpoints
+	// echoing the body, larger bodies are rejected with 413.
+	MaxBodySize int64 = 10 * 1024 * 1024
+
+	// BytesMax is the maximum number of bytes returned by /bytes endpoint.
+	BytesMax = 1024 * 1024 * 1024
+
+	// SaveDataBytesMax is the maximum number of bytes returned by /bytes
+	// endpoint to clients sending Save-Data: on.
+	SaveDataBytesMax = 64 * 1024
+
+	// StreamMax is the maximum number of objects written by /stream endpoint.
+	StreamMax = 100
+
+	// WordsMax is the maximum number of words returned by /words endpoint.
+	WordsMax = 10000
+
+	// ItemsMax is the maximum collection size served by /items endpoint.
+	ItemsMax = 100000
+
+	// StructMax is the maximum number of values returned by /struct endpoint.
+	StructMax = 1024 * 1024
+
+	// CookiesMax is the maximum number of cookies set by /cookies/set-many
+	// endpoint.
+	CookiesMax = 1000
+
+	// ResourcesMax is the maximum number of resources kept by /resources
+	// endpoint, the oldest ones are evicted beyond it.
+	ResourcesMax = 10000
+
+	// ResourcesBytesMax is the maximum total size of the resources kept by
+	// /resources endpoint, the oldest ones are evicted beyond it.
+	ResourcesBytesMax = 64 * 1024 * 1024
+
+	// ResourceTTL is the default time the resources created through
+	// /resources are kept for, see WithResourceTTL.
+	ResourceTTL = time.Hour
+
+	errBodyTooLarge = errors.New("request body too large")
+
+	// imageLastModified is the Last-Modified time of the generated images,
+	// which never change.
+	imageLastModified = time.Date(2016, time.January, 7, 0, 0, 0, 0, time.UTC)
 )
 
 // GetMux returns the mux with handlers for httpbin endpoints registered.
-func GetMux() *mux.Router {
+func GetMux(opts ...Option) *mux.Router {
+	o := newOptions(opts)
 
 	r := mux.NewRouter()
 	r.HandleFunc(`/`, HomeHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/ip`, IPHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/hostname`, HostnameHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/user-agent`, UserAgentHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/user-agent/parse`, UserAgentParseHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/http-version`, HTTPVersionHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/alpn`, ALPNHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/h2-info`, H2InfoHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/geo`, GeoHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/self`, SelfHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/limits`, LimitsHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/headers`, HeadersHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/headers/redacted`, RedactedHeadersHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/headers/ordered`, OrderedHeadersHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/dup-headers`, DupHeadersHandler)
+	r.HandleFunc(`/hop-by-hop`, HopByHopHandler)
+	r.HandleFunc(`/method`, MethodHandler)
+	r.HandleFunc(`/proxy-echo`, ProxyEchoHandler)
+	r.HandleFunc(`/request-line`, RequestLineHandler)
+	r.HandleFunc(`/scheme`, SchemeHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/timing`, TimingHandler)
+	r.HandleFunc(`/resolve`, ResolveHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/get`, GetHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/post`, PostHandler).Methods(http.MethodPost)
+	r.HandleFunc(`/put`, PutHandler).Methods(http.MethodPut)
+	r.HandleFunc(`/patch`, PatchHandler).Methods(http.MethodPatch)
+	r.HandleFunc(`/delete`, DeleteHandler).Methods(http.MethodDelete)
+	r.HandleFunc(`/anything`, AnythingHandler)
+	r.HandleFunc(`/anything/{path:.*}`, AnythingHandler)
+	r.HandleFunc(`/post/stream`, PostStreamHandler).Methods(http.MethodPost)
+	r.HandleFunc(`/stream-upload`, StreamUploadHandler).Methods(http.MethodPost)
+	r.HandleFunc(`/verify-hmac`, VerifyHMACHandler).Methods(http.MethodPost)
+	r.HandleFunc(`/verify-length`, VerifyLengthHandler).Methods(http.MethodPost)
+	r.HandleFunc(`/upload-trailer`, UploadTrailerHandler).Methods(http.MethodPost)
 	r.HandleFunc(`/redirect/{n:[\d]+}`, RedirectHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/absolute-redirect/{n:[\d]+}`, AbsoluteRedirectHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/redirect-post`, RedirectPostHandler).Methods(http.MethodPost)
+	r.HandleFunc(`/redirect-preserve`, RedirectPreserveHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/redirect-to`, RedirectToHandler).Methods(http.MethodGet, http.MethodHead).Queries("url", "{url:.+}")
 	r.HandleFunc(`/status/{code:[\d]+}`, StatusHandler)
+	r.HandleFunc(`/no-content`, NoContentHandler)
+	r.HandleFunc(`/reset`, ResetHandler)
+	r.HandleFunc(`/conn-count`, ConnCountHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/keepalive`, KeepAliveHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/bytes/{n:[\d]+}`, BytesHandler).Methods(http.MethodGet, http.MethodHead)
-	r.HandleFunc(`/delay/{n:\d+(?:\.\d+)?}`, DelayHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/magic/{type}`, MagicHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/throttle/{size:[\d]+}`, ThrottleHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/slow/{size:[\d]+}`, SlowHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/words/{count:[\d]+}`, WordsHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/encoding/mixed`, MixedEncodingHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/items`, ItemsHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/struct`, StructHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/protobuf`, ProtobufHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/msgpack`, MsgpackHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/fuzz-json`, FuzzJSONHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/delay/random`, RandomDelayHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/latency`, LatencyHandler).Methods(http.MethodGet, http.MethodHead)
+	r.Handle(`/delay/{n:\d+(?:\.\d+)?}`, limitConcurrency(o.delayLimit, http.HandlerFunc(DelayHandler))).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/stream/{n:[\d]+}`, StreamHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/stream-trailers/{n:[\d]+}`, StreamTrailersHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/task`, TaskHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/drip`, DripHandler).Methods(http.MethodGet, http.MethodHead).Queries(
 		"numbytes", `{numbytes:\d+}`,
 		"duration", `{duration:\d+(?:\.\d+)?}`)
 	r.HandleFunc(`/cookies`, CookiesHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/cookies/set`, SetCookiesHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/cookies/delete`, DeleteCookiesHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/cookies/clear`, ClearCookiesHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/cookies/set-many`, SetManyCookiesHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/cookies/validate`, ValidateCookiesHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/cache`, CacheHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/etag/canonicalize`, CanonicalizeETagHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/cache/{n:[\d]+}`, SetCacheHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/vary`, VaryHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/gzip`, GZIPHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/maybe-gzip`, MaybeGZIPHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/brotli`, BrotliHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/deflate`, DeflateHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/html`, HTMLHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/json`, JSONHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/xml`, XMLHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/yaml`, YAMLHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/robots.txt`, RobotsTXTHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/deny`, DenyHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/malformed-json`, MalformedJSONHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/basic-auth/{u}/{p}`, BasicAuthHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/hidden-basic-auth/{u}/{p}`, HiddenBasicAuthHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/bearer`, BearerHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/auth/echo`, AuthEchoHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/accept/parse`, AcceptParseHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/image/gif`, GIFHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/image/png`, PNGHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(`/image/jpeg`, JPEGHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/image/text`, TextImageHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/sse/heartbeat`, SSEHeartbeatHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/multipart`, MultipartHandler).Methods(http.MethodGet, http.MethodHead)
+
+	p := newPoller()
+	r.HandleFunc(`/poll`, p.pollHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/poll/trigger`, p.triggerHandler).Methods(http.MethodPost)
+
+	g := newGrower()
+	r.HandleFunc(`/growing`, g.growHandler).Methods(http.MethodGet)
+	r.HandleFunc(`/growing/reset`, g.resetHandler).Methods(http.MethodPost)
+
+	da := newDigestAuth()
+	r.HandleFunc(`/digest-auth/{qop:auth|auth-int}/{u}/{p}`, da.handler)
+
+	rs := newResourceStore(o.resourceTTL)
+	r.HandleFunc(`/resources`, rs.createHandler).Methods(http.MethodPost)
+	r.HandleFunc(`/resources/{id}`, rs.getHandler).Methods(http.MethodGet, http.MethodHead)
+
+	var rec *requestRecorder
+	if o.recordMax > 0 {
+		rec = newRequestRecorder(o.recordMax)
+		r.HandleFunc(`/requests`, rec.listHandler).Methods(http.MethodGet, http.MethodHead)
+		r.HandleFunc(`/requests/clear`, rec.clearHandler).Methods(http.MethodPost)
+	}
+	if o.debugStats {
+		r.HandleFunc(`/debug/stats`, DebugStatsHandler).Methods(http.MethodGet, http.MethodHead)
+	}
+
+	r.HandleFunc(`/greeting`, GreetingHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/spec`, specHandler(r)).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(`/openapi.json`, openAPIHandler(r)).Methods(http.MethodGet, http.MethodHead)
+
+	// answers OPTIONS for routes not handling it themselves, as well as
+	// other methods a route does not handle
+	r.MethodNotAllowedHandler = methodNotAllowedHandler(r)
+
+	r.Use(receivedAtMiddleware)
+	r.Use(connCounterMiddleware)
+	if rec != nil {
+		r.Use(rec.middleware)
+		// middleware only runs for matched routes, so record requests for
+		// unknown paths and methods through these handlers
+		r.NotFoundHandler = rec.middleware(http.NotFoundHandler())
+		r.MethodNotAllowedHandler = rec.middleware(r.MethodNotAllowedHandler)
+	}
+	if o.responseTime {
+		r.Use(responseTimeMiddleware)
+	}
+	if len(o.defaultHeaders) > 0 {
+		r.Use(defaultHeadersMiddleware(o.defaultHeaders))
+	}
+	if o.compression {
+		r.Use(compressionMiddleware)
+	}
+	if o.jsonKeyStyle != JSONKeyDefault {
+		r.Use(jsonKeyStyleMiddleware(o.jsonKeyStyle)) // must be last
+	}
 	return r
 }
 
@@ -96,14 +290,31 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
 	</html>`)
 }
 
-// IPHandler returns Origin IP.
+// IPHandler returns Origin IP, as plain text if the client prefers text/plain
+// over JSON.
 func IPHandler(w http.ResponseWriter, r *http.Request) {
 	h, _, _ := net.SplitHostPort(r.RemoteAddr)
+	w.Header().Add("Vary", "Accept")
+	if negotiateType(r, "application/json", "text/plain") == "text/plain" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, h+"\n")
+		return
+	}
 	if err := writeJSON(w, ipResponse{h}); err != nil {
 		writeErrorJSON(w, errors.Wrap(err, "failed to write json")) // TODO handle this error in writeJSON(w,v)
 	}
 }
 
+// HostnameHandler returns the hostname of the machine serving the request,
+// which tells apart replicas behind a load balancer. It is empty if the
+// hostname cannot be determined.
+func HostnameHandler(w http.ResponseWriter, r *http.Request) {
+	h, _ := os.Hostname()
+	if err := writeJSON(w, hostnameResponse{h}); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
 // UserAgentHandler returns user agent.
 func UserAgentHandler(w http.ResponseWriter, r *http.Request) {
 	if err := writeJSON(w, userAgentResponse{r.UserAgent()}); err != nil {
@@ -111,6 +322,194 @@ func UserAgentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// UserAgentParseHandler returns the browser, operating system and device type
+// parsed from the user agent, and whether it is a crawler.
+func UserAgentParseHandler(w http.ResponseWriter, r *http.Request) {
+	if err := writeJSON(w, parseUserAgent(r.UserAgent())); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// GeoHandler returns a canned location for the country given in the
+// CF-IPCountry or X-Country request header or, without those, the country of
+// the origin IP in a small synthetic table. Unknown countries are reported as
+// "XX" without a location.
+func GeoHandler(w http.ResponseWriter, r *http.Request) {
+	v := geoResponse{Country: "XX"}
+	country, source := "", ""
+	for _, k := range []string{"CF-IPCountry", "X-Country"} {
+		if c := r.Header.Get(k); c != "" {
+			country, source = strings.ToUpper(strings.TrimSpace(c)), "header"
+			break
+		}
+	}
+	if country == "" {
+		h, _, _ := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(h); ip != nil {
+			country, source = geoCountryForIP(ip), "ip"
+		}
+	}
+	if p, ok := geoCountries[country]; ok {
+		v = geoResponse{Country: country, Latitude: &p.lat, Longitude: &p.long, Source: source}
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// SelfHandler returns the base URL the client addressed the server with, as
+// seen by the client, and the absolute URL of the request.
+func SelfHandler(w http.ResponseWriter, r *http.Request) {
+	base := baseURL(r)
+	v := selfResponse{
+		BaseURL: base,
+		URL:     base + r.URL.RequestURI(),
+		Path:    r.URL.Path,
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// HTTPVersionHandler returns the HTTP protocol version of the request.
+func HTTPVersionHandler(w http.ResponseWriter, r *http.Request) {
+	v := httpVersionResponse{
+		Proto:      r.Proto,
+		ProtoMajor: r.ProtoMajor,
+		ProtoMinor: r.ProtoMinor,
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// ALPNHandler returns the application protocol negotiated with ALPN during
+// the TLS handshake.
+func ALPNHandler(w http.ResponseWriter, r *http.Request) {
+	v := alpnResponse{Proto: r.Proto}
+	if r.TLS == nil {
+		v.Note = "connection is not using TLS"
+	} else {
+		v.TLS = true
+		v.NegotiatedProtocol = r.TLS.NegotiatedProtocol
+		if v.NegotiatedProtocol == "" {
+			v.Note = "no protocol was negotiated with ALPN"
+		}
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// H2InfoHandler returns the protocol of the request and whether the server
+// supports HTTP/2 server push on it.
+func H2InfoHandler(w http.ResponseWriter, r *http.Request) {
+	_, push := unwrapResponseWriter(w).(http.Pusher)
+	v := h2InfoResponse{
+		Proto:         r.Proto,
+		HTTP2:         r.ProtoMajor == 2,
+		PushSupported: push,
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// LimitsHandler returns the limits enforced by the endpoints.
+func LimitsHandler(w http.ResponseWriter, r *http.Request) {
+	v := limitsResponse{
+		MaxBodySize:    MaxBodySize,
+		MaxBytes:       BytesMax,
+		MaxDelay:       DelayMax.Seconds(),
+		MaxStreamCount: StreamMax,
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// DebugStatsHandler returns the number of goroutines and memory statistics
+// of the server process. It is only registered with WithDebugStats.
+func DebugStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	v := debugStatsResponse{
+		Goroutines:  runtime.NumGoroutine(),
+		Alloc:       m.Alloc,
+		TotalAlloc:  m.TotalAlloc,
+		Sys:         m.Sys,
+		HeapAlloc:   m.HeapAlloc,
+		HeapInuse:   m.HeapInuse,
+		HeapObjects: m.HeapObjects,
+		NumGC:       m.NumGC,
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// specHandler returns a handler listing the path template, allowed methods and
+// required query parameters of each route registered on router.
+func specHandler(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var routes []specRoute
+		err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			tpl, err := route.GetPathTemplate()
+			if err != nil {
+				return nil // not a path route
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				methods = []string{"*"} // any method
+			}
+			queries, _ := route.GetQueriesTemplates()
+			routes = append(routes, specRoute{Path: tpl, Methods: methods, Queries: queries})
+			return nil
+		})
+		if err != nil {
+			writeErrorJSON(w, errors.Wrap(err, "failed to list routes"))
+			return
+		}
+		if err := writeJSON(w, specResponse{routes}); err != nil {
+			writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+		}
+	}
+}
+
+// methodNotAllowedHandler returns the handler router calls when a route
+// matches the requested path but not the method. It lists the methods allowed
+// for the path in the Allow header, answering OPTIONS requests with 200 and
+// other methods with 405.
+func methodNotAllowedHandler(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allow []string
+		for _, m := range []string{
+			http.MethodGet,
+			http.MethodHead,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		} {
+			req := *r
+			req.Method = m
+			var match mux.RouteMatch
+			// Match also succeeds with the NotFound and MethodNotAllowed
+			// handlers, which set MatchErr
+			if router.Match(&req, &match) && match.MatchErr == nil {
+				allow = append(allow, m)
+			}
+		}
+		w.Header().Set("Allow", strings.Join(append(allow, http.MethodOptions), ", "))
+		if r.Method != http.MethodOptions {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
 // HeadersHandler returns user agent.
 func HeadersHandler(w http.ResponseWriter, r *http.Request) {
 	if err := writeJSON(w, headersResponse{getHeaders(r)}); err != nil {
@@ -118,33 +517,391 @@ func HeadersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// GetHandler returns user agent.
+// redactedHeaders are the headers masked by /headers/redacted by default.
+var redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// RedactedHeadersHandler returns the headers like HeadersHandler, with the
+// values of redactedHeaders and those in the comma-separated 'redact' query
+// parameter masked, so that they can be shared safely. The scheme of
+// credentials and the names of cookies are kept.
+func RedactedHeadersHandler(w http.ResponseWriter, r *http.Request) {
+	names := redactedHeaders
+	for _, n := range strings.Split(r.URL.Query().Get("redact"), ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names[:len(names):len(names)], n)
+		}
+	}
+	hdr := getHeaders(r)
+	for _, n := range names {
+		k := http.CanonicalHeaderKey(n)
+		v, ok := hdr[k]
+		if !ok {
+			continue
+		}
+		switch k {
+		case "Authorization", "Proxy-Authorization":
+			if i := strings.IndexByte(v, ' '); i > 0 {
+				hdr[k] = v[:i] + " ***"
+				continue
+			}
+			hdr[k] = "***"
+		case "Cookie":
+			cookies := strings.Split(v, ";")
+			for i, c := range cookies {
+				name := strings.TrimSpace(strings.SplitN(c, "=", 2)[0])
+				cookies[i] = name + "=***"
+			}
+			hdr[k] = strings.Join(cookies, "; ")
+		default:
+			hdr[k] = "***"
+		}
+	}
+	if err := writeJSON(w, headersResponse{hdr}); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// hopByHopHeaders are the headers RFC 7230 section 6.1 requires proxies to
+// remove before forwarding a message.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection", // non-standard, but widely sent
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// HopByHopHandler returns the hop-by-hop headers that reached the server, both
+// the well-known ones and those listed in the Connection header, which a
+// correct proxy would have removed. With 'strip=1' the remaining end-to-end
+// headers are returned too, as they should be forwarded.
+func HopByHopHandler(w http.ResponseWriter, r *http.Request) {
+	hop := make(map[string]bool)
+	for _, k := range hopByHopHeaders {
+		hop[k] = true
+	}
+	for _, v := range r.Header["Connection"] {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				hop[http.CanonicalHeaderKey(k)] = true
+			}
+		}
+	}
+
+	v := hopByHopResponse{HopByHop: make(map[string]string)}
+	end := make(map[string]string)
+	for k, vv := range r.Header {
+		if hop[k] {
+			v.HopByHop[k] = strings.Join(vv, ", ")
+		} else {
+			end[k] = vv[0]
+		}
+	}
+	// net/http moves Transfer-Encoding out of the request headers
+	if len(r.TransferEncoding) > 0 {
+		v.HopByHop["Transfer-Encoding"] = strings.Join(r.TransferEncoding, ", ")
+	}
+	v.Present = len(v.HopByHop) > 0
+	if r.URL.Query().Get("strip") == "1" {
+		v.Headers = end
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// OrderedHeadersHandler returns the request headers as [name, value] pairs in
+// the order and spelling they were received. It requires the server to accept
+// connections through Listener and to set ConnContext, and only works over
+// HTTP/1.x without TLS.
+func OrderedHeadersHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := r.Context().Value(rawConnKey).(*recordingConn)
+	if !ok || r.ProtoMajor != 1 {
+		writeErrorJSONStatus(w, http.StatusNotImplemented, errors.New("raw request headers are only available over HTTP/1.x connections accepted by httpbin.Listener"))
+		return
+	}
+	hdrs, ok := c.rawHeaders(r.Method + " " + r.RequestURI + " " + r.Proto)
+	if !ok {
+		writeErrorJSON(w, errors.New("failed to find the raw request headers"))
+		return
+	}
+	if err := writeJSON(w, orderedHeadersResponse{hdrs}); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// dupHeaderNames are the headers reported by /dup-headers by default, whose
+// duplicates are commonly used to smuggle requests past intermediaries.
+var dupHeaderNames = []string{"Content-Length", "Host", "Transfer-Encoding"}
+
+// DupHeadersHandler reports how many fields of each header in the
+// comma-separated 'names' query parameter (default dupHeaderNames) the
+// request had, with their values, both as parsed by net/http and, when the
+// raw request head is recorded as for OrderedHeadersHandler, as received.
+// net/http moves Host and Transfer-Encoding out of the header map, collapses
+// identical Content-Length fields and rejects requests with conflicting ones
+// or several Host fields before they reach the handler.
+func DupHeadersHandler(w http.ResponseWriter, r *http.Request) {
+	names := dupHeaderNames
+	if v := r.URL.Query().Get("names"); v != "" {
+		names = nil
+		for _, n := range strings.Split(v, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				names = append(names, http.CanonicalHeaderKey(n))
+			}
+		}
+	}
+
+	var raw [][2]string
+	c, ok := r.Context().Value(rawConnKey).(*recordingConn)
+	ok = ok && r.ProtoMajor == 1 // HTTP/2 requests are not in the raw bytes
+	if ok {
+		raw, ok = c.rawHeaders(r.Method + " " + r.RequestURI + " " + r.Proto)
+	}
+
+	v := dupHeadersResponse{Headers: make(map[string]dupHeader, len(names))}
+	if !ok {
+		v.Note = "raw request headers are only available over HTTP/1.x connections accepted by httpbin.Listener"
+	}
+	for _, name := range names {
+		var h dupHeader
+		switch name {
+		case "Host":
+			h.Values = []string{r.Host}
+		case "Transfer-Encoding":
+			h.Values = r.TransferEncoding
+		default:
+			h.Values = r.Header[name]
+		}
+		if h.Values == nil {
+			h.Values = []string{}
+		}
+		h.Count = len(h.Values)
+		if ok {
+			for _, kv := range raw {
+				if strings.EqualFold(kv[0], name) {
+					h.RawValues = append(h.RawValues, kv[1])
+				}
+			}
+			n := len(h.RawValues)
+			h.RawCount = &n
+		}
+		v.Headers[name] = h
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// MethodHandler returns the request method and the effective method after
+// applying the X-HTTP-Method-Override header, the X-HTTP-Method header or
+// the '_method' query parameter, in that order of precedence.
+func MethodHandler(w http.ResponseWriter, r *http.Request) {
+	v := methodResponse{Method: r.Method, EffectiveMethod: r.Method}
+	for _, o := range []struct{ source, value string }{
+		{"X-HTTP-Method-Override", r.Header.Get("X-HTTP-Method-Override")},
+		{"X-HTTP-Method", r.Header.Get("X-HTTP-Method")},
+		{"_method", r.URL.Query().Get("_method")},
+	} {
+		if m := strings.TrimSpace(o.value); m != "" {
+			v.EffectiveMethod = strings.ToUpper(m)
+			v.OverrideSource = o.source
+			break
+		}
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// ProxyEchoHandler returns the request target as sent by the client and
+// the URL parsed from it. Requests in absolute form, as sent to a proxy
+// (e.g. "GET http://example.com/proxy-echo HTTP/1.1"), are routed by their
+// path, so they are matched here as well.
+func ProxyEchoHandler(w http.ResponseWriter, r *http.Request) {
+	v := proxyEchoResponse{
+		RequestURI:   r.RequestURI,
+		URL:          r.URL.String(),
+		Scheme:       r.URL.Scheme,
+		Host:         r.URL.Host,
+		Path:         r.URL.Path,
+		RawQuery:     r.URL.RawQuery,
+		HostHeader:   r.Host,
+		AbsoluteForm: r.URL.IsAbs(),
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// RequestLineHandler returns the method, request target and protocol version
+// of the request line exactly as received, before the target is parsed into
+// a URL. net/http splits the request line at its first two spaces, so the
+// request line reassembled from them is byte for byte what was sent.
+//
+// Requests are routed by their path, so targets in absolute form reach this
+// handler too, but CONNECT requests in authority form have no path and don't.
+func RequestLineHandler(w http.ResponseWriter, r *http.Request) {
+	v := requestLineResponse{
+		Method:      r.Method,
+		RequestURI:  r.RequestURI,
+		Proto:       r.Proto,
+		RequestLine: r.Method + " " + r.RequestURI + " " + r.Proto,
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// SchemeHandler returns the scheme of the request as derived from the TLS
+// connection, the X-Forwarded-Proto header and the proto of the Forwarded
+// header, the one links are built with, and whether the forwarded values
+// present agree with each other. The connection only matters without them, as
+// a proxy terminating TLS forwards https over plain HTTP.
+func SchemeHandler(w http.ResponseWriter, r *http.Request) {
+	v := schemeResponse{
+		Scheme:          requestScheme(r),
+		TLS:             tlsScheme(r),
+		XForwardedProto: forwardedProtoScheme(r),
+		Forwarded:       forwardedScheme(r),
+		Consistent:      true,
+	}
+	for _, s := range []string{v.XForwardedProto, v.Forwarded} {
+		if s != "" && s != v.Scheme { // also an invalid one, as it is not used
+			v.Consistent = false
+		}
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// TimingHandler reads the request body, up to MaxBodySize, and returns when
+// the request was received and responded to as seen by the server, with the
+// time spent reading the body and handling the request in milliseconds.
+func TimingHandler(w http.ResponseWriter, r *http.Request) {
+	received, ok := r.Context().Value(receivedAtKey).(time.Time)
+	if !ok { // not served through GetMux
+		received = time.Now()
+	}
+	readStart := time.Now()
+	n, err := io.Copy(ioutil.Discard, io.LimitReader(r.Body, MaxBodySize+1))
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.Wrap(err, "failed to read body"))
+		return
+	}
+	if n > MaxBodySize {
+		writeBodyError(w, errBodyTooLarge)
+		return
+	}
+	responded := time.Now()
+
+	v := timingResponse{
+		ReceivedAt:  received.UTC(),
+		RespondedAt: responded.UTC(),
+		BodyBytes:   n,
+		BodyReadMs:  float64(responded.Sub(readStart)) / float64(time.Millisecond),
+		HandlerMs:   float64(responded.Sub(received)) / float64(time.Millisecond),
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// ResolveHandler percent-decodes the path given in the 'target' query
+// parameter once more, in addition to the query decoding, and cleans it with
+// path.Clean, reporting each form and whether cleaning changed the path.
+func ResolveHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("'target' is required"))
+		return
+	}
+	decoded, err := url.PathUnescape(target)
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.Wrap(err, "failed to decode 'target'"))
+		return
+	}
+	cleaned := path.Clean(decoded)
+	v := resolveResponse{
+		Target:  target,
+		Decoded: decoded,
+		Cleaned: cleaned,
+		Changed: cleaned != decoded,
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// GetHandler returns user agent. The echoed headers can be limited with
+// a comma-separated 'show_headers' query parameter.
+//
+// With 'proxy_info=1' the response also reports whether the request came
+// through a proxy, judging by the Via, X-Forwarded-For and Forwarded headers,
+// and the proxies listed in the Via header.
+//
+// With 'shuffle=1' the keys of the response objects are written in a
+// pseudo-random order, reproducible with the 'seed' query parameter.
 func GetHandler(w http.ResponseWriter, r *http.Request) {
 	h, _, _ := net.SplitHostPort(r.RemoteAddr)
 
+	hdr := getHeaders(r)
+	if names := r.URL.Query().Get("show_headers"); names != "" {
+		hdr = filterHeaders(hdr, strings.Split(names, ","))
+	}
+
 	v := getResponse{
-		headersResponse: headersResponse{getHeaders(r)},
+		headersResponse: headersResponse{hdr},
 		ipResponse:      ipResponse{h},
+		URL:             requestURL(r),
 		Args:            flattenValues(r.URL.Query()),
 	}
+	if r.URL.Query().Get("proxy_info") == "1" {
+		proxied := false
+		for _, k := range []string{"Via", "X-Forwarded-For", "Forwarded"} {
+			if len(r.Header[k]) > 0 {
+				proxied = true
+			}
+		}
+		v.Proxied = &proxied
+		for _, via := range r.Header["Via"] {
+			for _, hop := range strings.Split(via, ",") {
+				v.Via = append(v.Via, strings.TrimSpace(hop))
+			}
+		}
+	}
 
-	if err := writeJSON(w, v); err != nil {
+	if err := writeEchoJSON(w, r, v); err != nil {
 		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
 	}
 }
 
-// PostHandler accept a post and echo its data back
+// PostHandler accept a post and echo its data back. Url-encoded and
+// multipart form bodies are returned parsed in the 'form' and 'files' fields
+// instead of as raw 'data'.
+//
+// With 'merge=1' the response also includes a 'params' field merging the
+// query arguments with the form body. A key present in both is
+// taken from the body only; repeated values of a key within one source are
+// returned as an array.
+//
+// The response keys can be shuffled with 'shuffle=1' like for GetHandler.
 func PostHandler(w http.ResponseWriter, r *http.Request) {
 	h, _, _ := net.SplitHostPort(r.RemoteAddr)
 
 	data, err := parseData(r)
 	if err != nil {
-		writeErrorJSON(w, errors.Wrap(err, "failed to read body"))
+		writeBodyError(w, err)
 		return
 	}
 
 	var jsonPayload interface{}
-	if strings.Contains(r.Header.Get("Content-Type"), "json") {
+	if len(data) > 0 && strings.Contains(r.Header.Get("Content-Type"), "json") {
 		err := json.Unmarshal(data, &jsonPayload)
 		if err != nil {
 			writeErrorJSON(w, errors.Wrap(err, "failed to read body"))
@@ -152,65 +909,424 @@ func PostHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	form, files, err := parseFormData(r, data)
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, err)
+		return
+	}
+
 	v := postResponse{
 		headersResponse: headersResponse{getHeaders(r)},
 		ipResponse:      ipResponse{h},
+		URL:             requestURL(r),
 		Args:            flattenValues(r.URL.Query()),
-		Data:            string(data),
+		Form:            flattenValues(form),
+		Files:           files,
 		JSON:            jsonPayload,
 	}
-
-	if err := writeJSON(w, v); err != nil {
-		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	if v.Files == nil {
+		v.Files = map[string]string{}
+	}
+	if form == nil {
+		v.Data = string(data)
 	}
-}
 
-// RedirectHandler returns a 302 Found response if n=1 pointing
-// to /get, otherwise to /redirect/(n-1)
-func RedirectHandler(w http.ResponseWriter, r *http.Request) {
-	n := mux.Vars(r)["n"]
-	i, _ := strconv.Atoi(n) // shouldn't fail due to route pattern
+	if r.URL.Query().Get("merge") == "1" {
+		v.Params = mergeValues(r.URL.Query(), form)
+	}
 
-	var loc string
-	if i <= 1 {
-		loc = "/get"
-	} else {
-		loc = fmt.Sprintf("/redirect/%d", i-1)
+	if err := writeEchoJSON(w, r, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
 	}
-	w.Header().Set("Location", loc)
-	w.WriteHeader(http.StatusFound)
 }
 
-// AbsoluteRedirectHandler returns a 302 Found response if n=1 pointing
-// to /host/get, otherwise to /host/absolute-redirect/(n-1)
-func AbsoluteRedirectHandler(w http.ResponseWriter, r *http.Request) {
-	n := mux.Vars(r)["n"]
-	i, _ := strconv.Atoi(n) // shouldn't fail due to route pattern
+// PutHandler accepts a PUT and echoes its data back like PostHandler.
+func PutHandler(w http.ResponseWriter, r *http.Request) {
+	PostHandler(w, r)
+}
 
-	var loc string
-	if i <= 1 {
-		loc = "/get"
-	} else {
-		loc = fmt.Sprintf("/absolute-redirect/%d", i-1)
-	}
+// PatchHandler accepts a PATCH and echoes its data back like PostHandler.
+func PatchHandler(w http.ResponseWriter, r *http.Request) {
+	PostHandler(w, r)
+}
 
-	w.Header().Set("Location", "http://"+r.Host+loc)
-	w.WriteHeader(http.StatusFound)
+// DeleteHandler accepts a DELETE and echoes its data back like PostHandler.
+// DELETE requests usually have no body, in which case 'data' is empty and
+// 'json' is null.
+func DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	PostHandler(w, r)
 }
 
-// RedirectToHandler returns a 302 Found response pointing to
-// the url query parameter
-func RedirectToHandler(w http.ResponseWriter, r *http.Request) {
+// AnythingHandler accepts a request with any method and echoes it back: its
+// method, URL, query arguments, headers and body, parsed into 'form' and
+// 'files' for form content types and into 'json' for JSON ones. A malformed
+// JSON body leaves 'json' null rather than failing the request.
+func AnythingHandler(w http.ResponseWriter, r *http.Request) {
+	h, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+	data, err := parseData(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	form, files, err := parseFormData(r, data)
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, err)
+		return
+	}
+
+	v := anythingResponse{
+		headersResponse: headersResponse{getHeaders(r)},
+		ipResponse:      ipResponse{h},
+		Method:          r.Method,
+		URL:             requestURL(r),
+		Args:            flattenValues(r.URL.Query()),
+		Form:            flattenValues(form),
+		Files:           files,
+	}
+	if v.Files == nil {
+		v.Files = map[string]string{}
+	}
+	if form == nil {
+		v.Data = string(data)
+	}
+	if len(data) > 0 && strings.Contains(r.Header.Get("Content-Type"), "json") {
+		json.Unmarshal(data, &v.JSON)
+	}
+
+	if err := writeEchoJSON(w, r, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// VerifyHMACHandler computes the HMAC-SHA256 of the request body keyed with
+// the 'secret' query parameter and compares it to the signature in the
+// 'header' request header (default X-Signature), encoded as 'hex' (default)
+// or 'base64' per the 'encoding' parameter. The signature is decoded and
+// compared as bytes, so hex in either case matches. A "sha256=" prefix on the
+// signature, as sent by GitHub webhooks, is ignored.
+func VerifyHMACHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	secret := q.Get("secret")
+	if secret == "" {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("'secret' is required"))
+		return
+	}
+	header := q.Get("header")
+	if header == "" {
+		header = "X-Signature"
+	}
+	var (
+		encode func([]byte) string
+		decode func(string) ([]byte, error)
+	)
+	switch q.Get("encoding") {
+	case "", "hex":
+		encode, decode = hex.EncodeToString, hex.DecodeString
+	case "base64":
+		encode, decode = base64.StdEncoding.EncodeToString, base64.StdEncoding.DecodeString
+	default:
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("encoding must be 'hex' or 'base64'"))
+		return
+	}
+
+	body, err := parseData(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	sum := mac.Sum(nil)
+	got, err := decode(strings.TrimPrefix(r.Header.Get(header), "sha256="))
+
+	v := verifyHMACResponse{
+		Valid:    err == nil && hmac.Equal(got, sum),
+		Expected: encode(sum),
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// VerifyLengthHandler compares the Content-Length declared by the request with
+// the number of body bytes actually received, up to MaxBodySize. A declared
+// length of -1 means the length was not declared, e.g. for chunked bodies.
+func VerifyLengthHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := io.Copy(ioutil.Discard, io.LimitReader(r.Body, MaxBodySize+1))
+	if n > MaxBodySize {
+		writeBodyError(w, errBodyTooLarge)
+		return
+	}
+	v := verifyLengthResponse{
+		Declared: r.ContentLength,
+		Received: n,
+		Match:    err == nil && (r.ContentLength < 0 || r.ContentLength == n),
+	}
+	if err != nil {
+		v.Error = err.Error() // e.g. body shorter than declared
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// UploadTrailerHandler verifies the SHA-256 digest of a chunked POST body
+// against the request trailer named by the 'trailer' query parameter
+// (default X-Checksum-Sha256). The digest may be hex or base64 encoded.
+// Trailers are only available once the body has been read to the end.
+func UploadTrailerHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("trailer")
+	if name == "" {
+		name = "X-Checksum-Sha256"
+	}
+
+	body, err := parseData(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	got := r.Trailer.Get(name)
+	if got == "" {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.Errorf("missing %s trailer", name))
+		return
+	}
+	sum := sha256.Sum256(body)
+	v := uploadTrailerResponse{
+		Valid:    got == hex.EncodeToString(sum[:]) || got == base64.StdEncoding.EncodeToString(sum[:]),
+		Expected: hex.EncodeToString(sum[:]),
+		Trailer:  got,
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// StreamUploadHandler reads a newline-delimited JSON request body
+// incrementally and writes a progress acknowledgement every 'every' lines
+// (default 10) while the upload is still in progress, followed by a final
+// acknowledgement once the body is exhausted.
+func StreamUploadHandler(w http.ResponseWriter, r *http.Request) {
+	every := 10
+	if v := r.URL.Query().Get("every"); v != "" {
+		var err error
+		every, err = strconv.Atoi(v)
+		if err != nil || every < 1 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'every'"))
+			return
+		}
+	}
+
+	// allow reading the body after the first ack is written on HTTP/1.x
+	if fd, ok := w.(interface {
+		EnableFullDuplex() error
+	}); ok {
+		fd.EnableFullDuplex()
+	}
+	disableCompression(r)
+
+	ack := func(v streamUploadAck) {
+		b, _ := json.Marshal(v)
+		w.Write(b)
+		w.Write([]byte{'\n'})
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	var v streamUploadAck
+	sc := bufio.NewScanner(r.Body)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		v.Lines++
+		if !json.Valid(line) {
+			v.Invalid++
+		}
+		if v.Lines%every == 0 {
+			ack(v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		v.Error = err.Error()
+	}
+	v.Done = true
+	ack(v)
+}
+
+// PostStreamHandler decodes a JSON array from the request body one element at
+// a time, up to MaxBodySize bytes, and writes each element back with its index
+// as a line of NDJSON as soon as it is decoded. A decoding error after the
+// first line is reported as a final error line.
+func PostStreamHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("request body must be a JSON array"))
+		return
+	}
+
+	// allow reading the body after the first line is written on HTTP/1.x
+	if fd, ok := w.(interface {
+		EnableFullDuplex() error
+	}); ok {
+		fd.EnableFullDuplex()
+	}
+	disableCompression(r)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	line := func(v interface{}) {
+		b, _ := json.Marshal(v)
+		w.Write(b)
+		w.Write([]byte{'\n'})
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	for i := 0; dec.More(); i++ {
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			line(errorResponse{errObj{err.Error()}})
+			return
+		}
+		line(postStreamRecord{Index: i, Record: v})
+	}
+	if _, err := dec.Token(); err != nil { // closing bracket
+		line(errorResponse{errObj{err.Error()}})
+	}
+}
+
+// RedirectHandler returns a 302 Found response if n=1 pointing
+// to /get, otherwise to /redirect/(n-1)
+func RedirectHandler(w http.ResponseWriter, r *http.Request) {
+	n := mux.Vars(r)["n"]
+	i, _ := strconv.Atoi(n) // shouldn't fail due to route pattern
+
+	var loc string
+	if i <= 1 {
+		loc = "/get"
+	} else {
+		loc = fmt.Sprintf("/redirect/%d", i-1)
+	}
+	w.Header().Set("Location", loc)
+	w.WriteHeader(http.StatusFound)
+}
+
+// AbsoluteRedirectHandler returns a 302 Found response if n=1 pointing
+// to /host/get, otherwise to /host/absolute-redirect/(n-1)
+func AbsoluteRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	n := mux.Vars(r)["n"]
+	i, _ := strconv.Atoi(n) // shouldn't fail due to route pattern
+
+	var loc string
+	if i <= 1 {
+		loc = "/get"
+	} else {
+		loc = fmt.Sprintf("/absolute-redirect/%d", i-1)
+	}
+
+	w.Header().Set("Location", baseURL(r)+loc)
+	w.WriteHeader(http.StatusFound)
+}
+
+// RedirectToHandler returns a 302 Found response pointing to
+// the url query parameter
+func RedirectToHandler(w http.ResponseWriter, r *http.Request) {
 	u := mux.Vars(r)["url"]
 	w.Header().Set("Location", u)
 	w.WriteHeader(http.StatusFound)
 }
 
-// StatusHandler returns a proper response for provided status code
+// RedirectPreserveHandler returns a 302 Found response pointing to the 'to'
+// query parameter (default /get). With 'keep_query=1' the rest of the request
+// query is appended to the target as received, so clients can be checked for
+// carrying it over. Fragments are never sent to servers, so a fragment can
+// only be part of 'to' itself, where it is kept after the query.
+func RedirectPreserveHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	loc := q.Get("to")
+	if loc == "" {
+		loc = "/get"
+	}
+	if q.Get("keep_query") == "1" {
+		var keep []string
+		for _, p := range strings.Split(r.URL.RawQuery, "&") {
+			k := p
+			if i := strings.IndexByte(k, '='); i >= 0 {
+				k = k[:i]
+			}
+			if k, _ = url.QueryUnescape(k); p != "" && k != "to" && k != "keep_query" {
+				keep = append(keep, p)
+			}
+		}
+		if len(keep) > 0 {
+			var frag string
+			if i := strings.IndexByte(loc, '#'); i >= 0 {
+				loc, frag = loc[:i], loc[i:]
+			}
+			sep := "?"
+			if strings.Contains(loc, "?") {
+				sep = "&"
+			}
+			loc += sep + strings.Join(keep, "&") + frag
+		}
+	}
+	w.Header().Set("Location", loc)
+	w.WriteHeader(http.StatusFound)
+}
+
+// RedirectPostHandler responds to a POST with the redirect status given in the
+// 'status' query parameter (301, 302, 303, 307 or 308, default 302) pointing
+// to the 'url' query parameter (default /get), along with a JSON body noting
+// the original method.
+func RedirectPostHandler(w http.ResponseWriter, r *http.Request) {
+	code := http.StatusFound
+	if v := r.URL.Query().Get("status"); v != "" {
+		code, _ = strconv.Atoi(v)
+	}
+	switch code {
+	case http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusSeeOther,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+	default:
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("status must be one of 301, 302, 303, 307 or 308"))
+		return
+	}
+	loc := r.URL.Query().Get("url")
+	if loc == "" {
+		loc = "/get"
+	}
+
+	w.Header().Set("Location", loc)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := writeJSON(w, redirectPostResponse{Method: r.Method, Status: code, Location: loc}); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// StatusHandler returns a proper response for provided status code. HEAD
+// requests receive the same status and headers without the body.
+//
+// The optional 'body' query parameter replaces the body, with the Content-Type
+// given in 'content_type' (default text/plain), to simulate error payloads.
+// As the body is reflected, only types browsers cannot run script from are
+// allowed, see inertContentType, and content sniffing is turned off.
 func StatusHandler(w http.ResponseWriter, r *http.Request) {
 	code, _ := strconv.Atoi(mux.Vars(r)["code"])
 
-	statusWritten := false
+	q := r.URL.Query()
+	ct := q.Get("content_type")
+	if ct != "" && !inertContentType(ct) {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.Errorf("content_type %q is not allowed", ct))
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	var body string
 	switch code {
 	case http.StatusMovedPermanently,
 		http.StatusFound,
@@ -221,19 +1337,13 @@ func StatusHandler(w http.ResponseWriter, r *http.Request) {
 	case http.StatusUnauthorized: // 401
 		w.Header().Set("WWW-Authenticate", `Basic realm="Fake Realm"`)
 	case http.StatusPaymentRequired: // 402
-		w.WriteHeader(code)
-		statusWritten = true
-		io.WriteString(w, "Fuck you, pay me!")
 		w.Header().Set("x-more-info", "http://vimeo.com/22053820")
+		body = "Fuck you, pay me!"
 	case http.StatusNotAcceptable: // 406
-		w.WriteHeader(code)
-		statusWritten = true
-		io.WriteString(w, `{"message": "Client did not request a supported media type.", "accept": ["image/webp", "image/svg+xml", "image/jpeg", "image/png", "image/*"]}`)
+		body = `{"message": "Client did not request a supported media type.", "accept": ["image/webp", "image/svg+xml", "image/jpeg", "image/png", "image/*"]}`
 	case http.StatusTeapot:
-		w.WriteHeader(code)
-		statusWritten = true
 		w.Header().Set("x-more-info", "http://tools.ietf.org/html/rfc2324")
-		io.WriteString(w, `
+		body = `
     -=[ teapot ]=-
 
        _...._
@@ -243,70 +1353,1063 @@ func StatusHandler(w http.ResponseWriter, r *http.Request) {
       |       ;/
       \_     _/
         '"""'
-`)
+`
 	}
-	if !statusWritten {
-		w.WriteHeader(code)
+	if v, ok := q["body"]; ok {
+		body = v[0]
+		if ct == "" {
+			ct = "text/plain; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", ct)
+	}
+	w.WriteHeader(code)
+	if r.Method != http.MethodHead {
+		io.WriteString(w, body)
 	}
 }
 
-// BytesHandler returns n random bytes of binary data and accepts an
-// optional 'seed' integer query parameter.
+// NoContentHandler returns a 204 No Content response without a body or any
+// entity headers.
+func NoContentHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetHandler abruptly closes the client connection. By default a partial
+// response is written first; with 'stage=before' the connection is closed
+// before anything is written. The socket is closed with SO_LINGER=0 so the
+// client observes a TCP reset rather than a clean EOF where possible.
+func ResetHandler(w http.ResponseWriter, r *http.Request) {
+	stage := r.URL.Query().Get("stage")
+	if stage != "" && stage != "before" && stage != "after" {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("'stage' must be 'before' or 'after'"))
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		writeErrorJSON(w, errors.New("connection hijacking is not supported"))
+		return
+	}
+	conn, bufrw, err := hj.Hijack()
+	if err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to hijack connection"))
+		return
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		tc.SetLinger(0)
+	}
+	defer conn.Close()
+
+	if stage != "before" {
+		io.WriteString(bufrw, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 1024\r\n\r\npartial respo")
+		bufrw.Flush()
+	}
+}
+
+// ConnCountHandler returns the number of requests served so far on the
+// underlying connection, including this one. It requires the server to be
+// configured with ConnContext.
+func ConnCountHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := r.Context().Value(connCounterKey).(*int64)
+	if !ok {
+		writeErrorJSONStatus(w, http.StatusNotImplemented,
+			errors.New("connection tracking is not enabled, set http.Server.ConnContext to httpbin.ConnContext"))
+		return
+	}
+	if err := writeJSON(w, connCountResponse{atomic.LoadInt64(c)}); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// KeepAliveHandler returns the timeouts, in seconds, of the http.Server
+// serving the request, zero meaning no timeout. As net/http does, the idle
+// timeout falls back to the read timeout and the read header timeout to the
+// read timeout when they are not set.
+func KeepAliveHandler(w http.ResponseWriter, r *http.Request) {
+	srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server)
+	if !ok {
+		writeErrorJSONStatus(w, http.StatusNotImplemented, errors.New("request is not served by an http.Server"))
+		return
+	}
+	v := keepAliveResponse{
+		ReadTimeout:       srv.ReadTimeout.Seconds(),
+		ReadHeaderTimeout: srv.ReadHeaderTimeout.Seconds(),
+		WriteTimeout:      srv.WriteTimeout.Seconds(),
+		IdleTimeout:       srv.IdleTimeout.Seconds(),
+	}
+	if srv.ReadHeaderTimeout == 0 {
+		v.ReadHeaderTimeout = v.ReadTimeout
+	}
+	if srv.IdleTimeout == 0 {
+		v.IdleTimeout = v.ReadTimeout
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// BytesHandler returns min(n, BytesMax) random bytes of binary data and
+// accepts an optional 'seed' integer query parameter, or X-Seed header if the
+// parameter is absent. The data is gzip-encoded if the client accepts it, and
+// has a Content-Length otherwise. Either way it is streamed without being
+// held in memory.
+//
+// With 'entropy=low' the data consists of long runs of repeated bytes, which
+// compresses well, instead of incompressible random bytes ('entropy=high').
+//
+// Clients sending Save-Data: on receive at most SaveDataBytesMax bytes.
 func BytesHandler(w http.ResponseWriter, r *http.Request) {
 	n, _ := strconv.Atoi(mux.Vars(r)["n"]) // shouldn't fail due to route pattern
+	if n > BytesMax {
+		n = BytesMax
+	}
+	if saveData(w, r) && n > SaveDataBytesMax {
+		n = SaveDataBytesMax
+	}
+
+	var lowEntropy bool
+	switch r.URL.Query().Get("entropy") {
+	case "", "high":
+	case "low":
+		lowEntropy = true
+	default:
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("entropy must be 'low' or 'high'"))
+		return
+	}
 
 	seedStr := r.URL.Query().Get("seed")
+	if seedStr == "" {
+		seedStr = r.Header.Get("X-Seed")
+	}
 	if seedStr == "" {
 		seedStr = fmt.Sprintf("%d", time.Now().UnixNano())
 	}
 
 	seed, _ := strconv.ParseInt(seedStr, 10, 64) // shouldn't fail due to route pattern
+
+	var out io.Writer = w
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Add("Vary", "Accept-Encoding")
+		ww := gzip.NewWriter(w)
+		defer ww.Close() // flush
+		out = ww
+	} else {
+		w.Header().Set("Content-Length", strconv.Itoa(n))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	writeRandomBytes(out, rand.New(rand.NewSource(seed)), n, lowEntropy)
+}
+
+// writeRandomBytes streams n bytes generated by rnd to w, as runs of repeated
+// bytes if lowEntropy is set. Bytes are generated a chunk of BinaryChunkSize
+// at a time, so memory use does not depend on n, and generation stops at the
+// first write error, e.g. when the client went away.
+func writeRandomBytes(w io.Writer, rnd *rand.Rand, n int, lowEntropy bool) error {
+	buf := make([]byte, BinaryChunkSize)
+	for n > 0 {
+		if n < len(buf) {
+			buf = buf[:n] // last chunk
+		}
+		if lowEntropy {
+			fillRuns(rnd, buf)
+		} else {
+			rnd.Read(buf) // will never return err
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		n -= len(buf)
+	}
+	return nil
+}
+
+// magicTypes are the file types served by /magic with their signatures.
+var magicTypes = map[string]struct {
+	contentType string
+	signature   []byte
+}{
+	"png": {"image/png", []byte("\x89PNG\r\n\x1a\n")},
+	"pdf": {"application/pdf", []byte("%PDF-1.7\n")},
+	"zip": {"application/zip", []byte("PK\x03\x04")},
+	"gif": {"image/gif", []byte("GIF89a")},
+}
+
+// MagicHandler returns 'n' bytes (default 512, at most BytesMax) starting
+// with the magic signature of the file type in the path, followed by
+// pseudo-random padding reproducible with the optional 'seed' integer
+// parameter (default 0). The result is not a valid file beyond its signature.
+func MagicHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := magicTypes[mux.Vars(r)["type"]]
+	if !ok {
+		writeErrorJSONStatus(w, http.StatusNotFound, errors.New("type must be one of 'png', 'pdf', 'zip' or 'gif'"))
+		return
+	}
+	q := r.URL.Query()
+	n := 512
+	if v := q.Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 0 || n > BytesMax {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.Errorf("n must be between 0 and %d", BytesMax))
+			return
+		}
+	}
+	if n < len(t.signature) {
+		n = len(t.signature)
+	}
+	var seed int64
+	if v := q.Get("seed"); v != "" {
+		var err error
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'seed'"))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", t.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(n))
+	w.Write(t.signature)
+	n -= len(t.signature)
 	rnd := rand.New(rand.NewSource(seed))
 	buf := make([]byte, BinaryChunkSize)
 	for n > 0 {
 		rnd.Read(buf) // will never return err
-		if n >= len(buf) {
-			n -= len(buf)
-			w.Write(buf)
-		} else {
-			// last chunk
-			w.Write(buf[:n])
-			break
+		if n < len(buf) {
+			buf = buf[:n]
+		}
+		if _, err := w.Write(buf); err != nil {
+			return // client went away
+		}
+		n -= len(buf)
+	}
+}
+
+// ThrottleHandler streams min(size, BytesMax) bytes of deterministic data at
+// about the rate given in the 'kbps' query parameter (kilobits per second,
+// default 128).
+func ThrottleHandler(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.Atoi(mux.Vars(r)["size"]) // shouldn't fail due to route pattern
+	if n > BytesMax {
+		n = BytesMax
+	}
+	kbps := 128.0
+	if v := r.URL.Query().Get("kbps"); v != "" {
+		var err error
+		if kbps, err = strconv.ParseFloat(v, 64); err != nil || kbps <= 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'kbps'"))
+			return
+		}
+	}
+	disableCompression(r)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(n))
+	tw := &throttledWriter{w: w, r: r, bytesPerSec: kbps * 1000 / 8, start: time.Now()}
+	pattern := []byte("0123456789abcdefghijklmnopqrstuvwxyz\n")
+	for n > 0 {
+		b := pattern
+		if n < len(b) {
+			b = b[:n]
+		}
+		if _, err := tw.Write(b); err != nil {
+			return // client went away
+		}
+		n -= len(b)
+	}
+}
+
+// SlowHandler writes min(size, BytesMax) bytes one at a time, sleeping
+// 'per-byte-ms' milliseconds (default 10) after each, but gives up once
+// 'max-seconds' (default 30, at most SlowMax) have elapsed. The full size is
+// declared in Content-Length, so giving up truncates the response and the
+// connection is closed.
+func SlowHandler(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.Atoi(mux.Vars(r)["size"]) // shouldn't fail due to route pattern
+	if n > BytesMax {
+		n = BytesMax
+	}
+	q := r.URL.Query()
+	perByte := 10 * time.Millisecond
+	if v := q.Get("per-byte-ms"); v != "" {
+		ms, err := strconv.ParseFloat(v, 64)
+		if err != nil || ms < 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'per-byte-ms'"))
+			return
+		}
+		perByte = time.Duration(ms * float64(time.Millisecond))
+	}
+	max := 30 * time.Second
+	if v := q.Get("max-seconds"); v != "" {
+		sec, err := strconv.ParseFloat(v, 64)
+		if err != nil || sec <= 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'max-seconds'"))
+			return
+		}
+		max = time.Duration(sec * float64(time.Second))
+	}
+	if max > SlowMax {
+		max = SlowMax
+	}
+	disableCompression(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), max)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(n))
+	for i := 0; i < n; i++ {
+		if _, err := w.Write([]byte{'*'}); err != nil {
+			return // client went away
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if !sleepContext(r, perByte) {
+			return // gave up, or client went away
+		}
+	}
+}
+
+// throttledWriter paces writes to w to about bytesPerSec, flushing after each
+// chunk of roughly a tenth of a second worth of data.
+type throttledWriter struct {
+	w           http.ResponseWriter
+	r           *http.Request
+	bytesPerSec float64
+	start       time.Time
+	written     int
+}
+
+func (t *throttledWriter) Write(b []byte) (int, error) {
+	chunk := int(t.bytesPerSec / 10)
+	if chunk < 1 {
+		chunk = 1
+	}
+	var total int
+	for len(b) > 0 {
+		if t.written%chunk == 0 { // wait until the bytes written so far are due
+			due := t.start.Add(time.Duration(float64(t.written) / t.bytesPerSec * float64(time.Second)))
+			if !sleepContext(t.r, time.Until(due)) {
+				return total, t.r.Context().Err()
+			}
+		}
+		c := chunk - t.written%chunk
+		if c > len(b) {
+			c = len(b)
+		}
+		n, err := t.w.Write(b[:c])
+		total += n
+		t.written += n
+		if err != nil {
+			return total, err
+		}
+		if t.written%chunk == 0 {
+			if f, ok := t.w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		b = b[c:]
+	}
+	return total, nil
+}
+
+// fillRuns fills b with runs of up to 4 KiB of the same random byte.
+func fillRuns(rnd *rand.Rand, b []byte) {
+	const run = 4 * 1024
+	for i := 0; i < len(b); i += run {
+		c := byte(rnd.Intn(256))
+		for j := i; j < i+run && j < len(b); j++ {
+			b[j] = c
+		}
+	}
+}
+
+// WordsHandler returns count space-separated pseudo-random words as plain text
+// and accepts an optional 'seed' integer query parameter to make the text
+// reproducible.
+func WordsHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(mux.Vars(r)["count"])
+	if err != nil || n > WordsMax {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.Errorf("count must be at most %d", WordsMax))
+		return
+	}
+
+	seed := time.Now().UnixNano()
+	if v := r.URL.Query().Get("seed"); v != "" {
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'seed'"))
+			return
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			bw.WriteByte(' ')
+		}
+		bw.WriteString(words[rnd.Intn(len(words))])
+	}
+	bw.Flush()
+}
+
+// StructHandler returns 'count' (default 16) pseudo-random uint32 values as
+// binary, little-endian unless 'endian=big' is given. Values are
+// reproducible with the optional 'seed' integer parameter.
+func StructHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	n := 16
+	if v := q.Get("count"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 0 || n > StructMax {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.Errorf("count must be between 0 and %d", StructMax))
+			return
+		}
+	}
+
+	var order binary.ByteOrder
+	switch q.Get("endian") {
+	case "", "little":
+		order = binary.LittleEndian
+	case "big":
+		order = binary.BigEndian
+	default:
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("endian must be 'little' or 'big'"))
+		return
+	}
+
+	seed := time.Now().UnixNano()
+	if v := q.Get("seed"); v != "" {
+		var err error
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'seed'"))
+			return
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(n*4))
+	bw := bufio.NewWriterSize(w, BinaryChunkSize)
+	for i := 0; i < n; i++ {
+		if err := binary.Write(bw, order, rnd.Uint32()); err != nil {
+			return // client went away
+		}
+	}
+	bw.Flush()
+}
+
+// ProtobufHandler returns a protocol buffers encoded Item message (see
+// protoItem) with pseudo-random field values, which are reproducible with the
+// optional 'seed' integer query parameter.
+func ProtobufHandler(w http.ResponseWriter, r *http.Request) {
+	m, err := seededItem(r)
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, err)
+		return
+	}
+	b := m.marshal()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Write(b)
+}
+
+// MsgpackHandler returns the slideshow of JSONHandler encoded as MessagePack
+// maps.
+func MsgpackHandler(w http.ResponseWriter, r *http.Request) {
+	b := sampleSlideshow.marshalMsgpack()
+	w.Header().Set("Content-Type", "application/msgpack")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Write(b)
+}
+
+// FuzzJSONHandler returns a JSON document that always matches the fuzzDocument
+// schema but has pseudo-random values, array lengths and nesting, including
+// edge cases like empty arrays, nulls, long strings and non-ASCII text.
+// Payloads are reproducible with the optional 'seed' integer query parameter.
+func FuzzJSONHandler(w http.ResponseWriter, r *http.Request) {
+	seed := time.Now().UnixNano()
+	if v := r.URL.Query().Get("seed"); v != "" {
+		var err error
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'seed'"))
+			return
+		}
+	}
+	if err := writeJSON(w, newFuzzGenerator(seed).document()); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// seededItem returns an item with pseudo-random field values generated from
+// the optional 'seed' integer query parameter.
+func seededItem(r *http.Request) (protoItem, error) {
+	seed := time.Now().UnixNano()
+	if v := r.URL.Query().Get("seed"); v != "" {
+		var err error
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return protoItem{}, errors.New("failed to parse 'seed'")
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	m := protoItem{
+		ID:     rnd.Int63n(1000000) + 1,
+		Name:   words[rnd.Intn(len(words))] + "-" + words[rnd.Intn(len(words))],
+		Values: make([]uint32, 5),
+		Active: rnd.Intn(2) == 1,
+		Score:  float64(rnd.Intn(10000)) / 100,
+	}
+	for i := range m.Values {
+		m.Values[i] = uint32(rnd.Intn(1000))
+	}
+	return m, nil
+}
+
+// ItemsHandler returns a page of a deterministic collection of 'total' items
+// (default 100) split into pages of 'per_page' items (default 20, at most
+// 100). Links to the first, previous, next and last pages are returned in
+// the Link header. Pages out of range are responded with 404.
+func ItemsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	intParam := func(name string, def, max int) (int, error) {
+		v := q.Get(name)
+		if v == "" {
+			return def, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > max {
+			return 0, errors.Errorf("'%s' must be an integer between 0 and %d", name, max)
+		}
+		return n, nil
+	}
+	page, err := intParam("page", 1, ItemsMax)
+	if err == nil && page < 1 {
+		err = errors.New("'page' must be at least 1")
+	}
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, err)
+		return
+	}
+	perPage, err := intParam("per_page", 20, 100)
+	if err == nil && perPage < 1 {
+		err = errors.New("'per_page' must be at least 1")
+	}
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, err)
+		return
+	}
+	total, err := intParam("total", 100, ItemsMax)
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, err)
+		return
+	}
+
+	last := (total + perPage - 1) / perPage
+	if last == 0 {
+		last = 1 // an empty collection still has an empty first page
+	}
+	if page > last {
+		writeErrorJSONStatus(w, http.StatusNotFound, errors.Errorf("page %d out of range, last page is %d", page, last))
+		return
+	}
+
+	pageURL := func(n int) string {
+		u := *r.URL
+		v := u.Query()
+		v.Set("page", strconv.Itoa(n))
+		v.Set("per_page", strconv.Itoa(perPage))
+		v.Set("total", strconv.Itoa(total))
+		u.RawQuery = v.Encode()
+		return u.RequestURI()
+	}
+	resp := itemsResponse{Page: page, PerPage: perPage, Total: total, Items: []item{}}
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, pageURL(1))}
+	if page > 1 {
+		resp.Prev = pageURL(page - 1)
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, resp.Prev))
+	}
+	if page < last {
+		resp.Next = pageURL(page + 1)
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, resp.Next))
+	}
+	links = append(links, fmt.Sprintf(`<%s>; rel="last"`, pageURL(last)))
+	w.Header().Set("Link", strings.Join(links, ", "))
+
+	for id := (page-1)*perPage + 1; id <= total && id <= page*perPage; id++ {
+		resp.Items = append(resp.Items, item{ID: id, Name: fmt.Sprintf("item-%d", id)})
+	}
+	if err := writeJSON(w, resp); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// invalidUTF8 are byte sequences that are not valid UTF-8.
+var invalidUTF8 = [][]byte{
+	{0xff},             // never valid
+	{0x80},             // unexpected continuation byte
+	{0xc3},             // truncated 2-byte sequence
+	{0xe2, 0x82},       // truncated 3-byte sequence
+	{0xc0, 0xaf},       // overlong encoding of '/'
+	{0xed, 0xa0, 0x80}, // UTF-16 surrogate half
+}
+
+// MixedEncodingHandler intentionally returns text labeled as UTF-8 that mixes
+// valid words, including multi-byte characters, with invalid UTF-8 byte
+// sequences. The content is reproducible with the optional 'seed' integer
+// query parameter.
+func MixedEncodingHandler(w http.ResponseWriter, r *http.Request) {
+	seed := time.Now().UnixNano()
+	if v := r.URL.Query().Get("seed"); v != "" {
+		var err error
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'seed'"))
+			return
+		}
+	}
+
+	valid := []string{"héllo", "naïve", "日本語", "Ωμέγα", "🙂"}
+	rnd := rand.New(rand.NewSource(seed))
+	var b bytes.Buffer
+	for i := 0; i < 32; i++ {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		switch {
+		case i%4 == 1: // every fourth segment is invalid
+			b.Write(invalidUTF8[rnd.Intn(len(invalidUTF8))])
+		case rnd.Intn(2) == 0:
+			b.WriteString(valid[rnd.Intn(len(valid))])
+		default:
+			b.WriteString(words[rnd.Intn(len(words))])
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// DelayHandler delays responding for min(n, 10) seconds and responds
+// with /get endpoint
+func DelayHandler(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.ParseFloat(mux.Vars(r)["n"], 64) // shouldn't fail due to route pattern
+
+	// allow only millisecond precision
+	duration := time.Millisecond * time.Duration(n*float64(time.Second/time.Millisecond))
+	if duration > DelayMax {
+		duration = DelayMax
+	}
+	if !sleepContext(r, duration) {
+		return // client went away
+	}
+	GetHandler(w, r)
+}
+
+// limitConcurrency returns h, responding with 503 Service Unavailable and a
+// Retry-After header instead while max requests are in flight in h. A max of
+// zero means no limit.
+func limitConcurrency(max int, h http.Handler) http.Handler {
+	if max <= 0 {
+		return h
+	}
+	var inFlight int64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&inFlight, 1) > int64(max) {
+			atomic.AddInt64(&inFlight, -1)
+			w.Header().Set("Retry-After", "1")
+			writeErrorJSONStatus(w, http.StatusServiceUnavailable, errors.New("too many concurrent requests"))
+			return
+		}
+		defer atomic.AddInt64(&inFlight, -1)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// RandomDelayHandler delays responding for a uniformly random duration between
+// the 'min' and 'max' query parameters (in seconds, clamped to DelayMax) and
+// responds with /get endpoint.
+func RandomDelayHandler(w http.ResponseWriter, r *http.Request) {
+	min, max := 0.0, DelayMax.Seconds()
+	if v := r.URL.Query().Get("min"); v != "" {
+		var err error
+		if min, err = strconv.ParseFloat(v, 64); err != nil || min < 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'min'"))
+			return
+		}
+	}
+	if v := r.URL.Query().Get("max"); v != "" {
+		var err error
+		if max, err = strconv.ParseFloat(v, 64); err != nil || max < 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'max'"))
+			return
+		}
+	}
+	if min > max {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("'min' must not be greater than 'max'"))
+		return
+	}
+
+	n := min + rand.Float64()*(max-min)
+	duration := time.Millisecond * time.Duration(n*float64(time.Second/time.Millisecond))
+	if duration > DelayMax {
+		duration = DelayMax
+	}
+	if !sleepContext(r, duration) {
+		return // client went away
+	}
+	GetHandler(w, r)
+}
+
+// LatencyHandler delays responding for a duration in seconds sampled from the
+// distribution named by 'dist' and responds with /get endpoint:
+//
+//   - normal (default) with 'mean' (default 0.2) and 'stddev' (default 0.05)
+//   - exponential with 'mean' (default 0.2)
+//   - uniform between 'min' (default 0) and 'max' (default 1)
+//
+// The sample is clamped between 0 and DelayMax and reported in the
+// X-Latency-Ms header. It is reproducible with the optional 'seed' integer
+// parameter.
+func LatencyHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	param := func(name string, def float64) (float64, error) {
+		v := q.Get(name)
+		if v == "" {
+			return def, nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return 0, errors.Errorf("failed to parse '%s'", name)
+		}
+		return f, nil
+	}
+	seed := time.Now().UnixNano()
+	if v := q.Get("seed"); v != "" {
+		var err error
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'seed'"))
+			return
+		}
+	}
+	rnd := rand.New(rand.NewSource(seed))
+
+	var sec float64
+	switch dist := q.Get("dist"); dist {
+	case "", "normal":
+		mean, err := param("mean", 0.2)
+		if err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, err)
+			return
+		}
+		stddev, err := param("stddev", 0.05)
+		if err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, err)
+			return
+		}
+		sec = mean + rnd.NormFloat64()*stddev
+	case "exponential":
+		mean, err := param("mean", 0.2)
+		if err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, err)
+			return
+		}
+		sec = rnd.ExpFloat64() * mean
+	case "uniform":
+		min, err := param("min", 0)
+		if err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, err)
+			return
+		}
+		max, err := param("max", 1)
+		if err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, err)
+			return
+		}
+		if min > max {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("'min' must not be greater than 'max'"))
+			return
+		}
+		sec = min + rnd.Float64()*(max-min)
+	default:
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.Errorf("unknown distribution %q, must be normal, exponential or uniform", dist))
+		return
+	}
+
+	// clamp before converting, as large samples overflow a Duration
+	sec = math.Min(math.Max(sec, 0), DelayMax.Seconds())
+	duration := time.Millisecond * time.Duration(sec*float64(time.Second/time.Millisecond))
+	if !sleepContext(r, duration) {
+		return // client went away
+	}
+	w.Header().Set("X-Latency-Ms", strconv.FormatInt(int64(duration/time.Millisecond), 10))
+	GetHandler(w, r)
+}
+
+// StreamHandler writes min(n, StreamMax) json objects, each to a new line
+// every second.
+//
+// The optional 'ramp' query parameter scales the interval between
+// consecutive objects linearly from StreamInterval to StreamInterval*ramp
+// over the stream.
+//
+// With 'checksum=1' each object has a 'hash' field holding the hex SHA-256
+// of the previous line, including its newline (of no bytes for the first
+// line). As each line carries the hash of the one before, the hashes chain
+// and a dropped or reordered line breaks the chain from there on.
+func StreamHandler(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.Atoi(mux.Vars(r)["n"]) // shouldn't fail due to route pattern
+	if n > StreamMax {
+		n = StreamMax
+	}
+	ramp := 1.0
+	if v := r.URL.Query().Get("ramp"); v != "" {
+		var err error
+		if ramp, err = strconv.ParseFloat(v, 64); err != nil || ramp <= 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'ramp'"))
+			return
+		}
+	}
+	// interval returns the time to wait before writing object i, where the
+	// gaps between objects 0..n-1 go from StreamInterval to StreamInterval*ramp.
+	interval := func(i int) time.Duration {
+		if i == 0 || n <= 2 {
+			return StreamInterval
+		}
+		f := 1 + (ramp-1)*float64(i-1)/float64(n-2)
+		return time.Duration(float64(StreamInterval) * f)
+	}
+
+	checksum := r.URL.Query().Get("checksum") == "1"
+
+	disableCompression(r)
+	nl := []byte{'\n'}
+	prev := sha256.Sum256(nil)
+	// allow only millisecond precision
+	for i := 0; i < n; i++ {
+		if !sleepContext(r, interval(i)) {
+			return // client went away
+		}
+		var hash string
+		if checksum {
+			hash = hex.EncodeToString(prev[:])
+		}
+		b, _ := json.Marshal(struct {
+			N    int       `json:"n"`
+			Time time.Time `json:"time"`
+			Hash string    `json:"hash,omitempty"`
+		}{i, time.Now().UTC(), hash})
+		b = append(b, nl...)
+		prev = sha256.Sum256(b)
+		w.Write(b)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+// StreamTrailersHandler writes min(n, StreamMax) lines of JSON objects like
+// StreamHandler, every 'interval' seconds (default 1, at most DelayMax), then
+// summarizes the stream in the X-Stream-Count, X-Stream-Bytes and
+// X-Stream-Duration-Ms trailers. Trailers require chunked encoding, so the
+// response has no Content-Length.
+func StreamTrailersHandler(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.Atoi(mux.Vars(r)["n"]) // shouldn't fail due to route pattern
+	if n > StreamMax {
+		n = StreamMax
+	}
+	interval := StreamInterval
+	if v := r.URL.Query().Get("interval"); v != "" {
+		sec, err := strconv.ParseFloat(v, 64)
+		if err != nil || sec < 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'interval'"))
+			return
+		}
+		interval = time.Millisecond * time.Duration(sec*float64(time.Second/time.Millisecond))
+	}
+	if interval > DelayMax {
+		interval = DelayMax
+	}
+	disableCompression(r)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Trailer", "X-Stream-Count, X-Stream-Bytes, X-Stream-Duration-Ms")
+	began := time.Now()
+	count, size := 0, 0
+	for i := 0; i < n; i++ {
+		if !sleepContext(r, interval) {
+			return // client went away
+		}
+		b, _ := json.Marshal(struct {
+			N    int       `json:"n"`
+			Time time.Time `json:"time"`
+		}{i, time.Now().UTC()})
+		written, _ := w.Write(append(b, '\n'))
+		count++
+		size += written
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	w.Header().Set("X-Stream-Count", strconv.Itoa(count))
+	w.Header().Set("X-Stream-Bytes", strconv.Itoa(size))
+	w.Header().Set("X-Stream-Duration-Ms", strconv.FormatInt(int64(time.Since(began)/time.Millisecond), 10))
+}
+
+// TaskHandler simulates a task taking 'duration' seconds (default 10, at most
+// TaskMax), streaming its progress as lines of JSON every 'interval' seconds
+// (default 1). Percentages go from 0 to 100, followed by a final done line.
+func TaskHandler(w http.ResponseWriter, r *http.Request) {
+	parseSeconds := func(name string, def time.Duration) (time.Duration, error) {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			return def, nil
+		}
+		sec, err := strconv.ParseFloat(v, 64)
+		if err != nil || sec < 0 {
+			return 0, errors.Errorf("failed to parse '%s'", name)
+		}
+		return time.Millisecond * time.Duration(sec*float64(time.Second/time.Millisecond)), nil
+	}
+	duration, err := parseSeconds("duration", 10*time.Second)
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, err)
+		return
+	}
+	if duration > TaskMax {
+		duration = TaskMax
+	}
+	interval, err := parseSeconds("interval", time.Second)
+	if err != nil || interval <= 0 {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'interval'"))
+		return
+	}
+	steps := int((duration + interval - 1) / interval)
+	if steps < 1 {
+		steps = 1
+	}
+	disableCompression(r)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	enc := json.NewEncoder(w)
+	flush := func() {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	began := time.Now()
+	for i := 0; i <= steps; i++ {
+		if i > 0 {
+			// sleep until the i-th step is due, so time spent writing does
+			// not make the task run late
+			d := duration*time.Duration(i)/time.Duration(steps) - time.Since(began)
+			if !sleepContext(r, d) {
+				return // client went away
+			}
+		}
+		enc.Encode(struct {
+			Percent int `json:"percent"`
+		}{i * 100 / steps})
+		flush()
+	}
+	enc.Encode(struct {
+		Done bool `json:"done"`
+	}{true})
+	flush()
+}
+
+// SSEHeartbeatHandler writes 'count' (default 5, at most StreamMax)
+// server-sent event comment lines every 'interval' seconds (default 1, at most
+// DelayMax), followed by a single data event.
+func SSEHeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	n := 5
+	if v := r.URL.Query().Get("count"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'count'"))
+			return
+		}
+	}
+	if n > StreamMax {
+		n = StreamMax
+	}
+	interval := time.Second
+	if v := r.URL.Query().Get("interval"); v != "" {
+		sec, err := strconv.ParseFloat(v, 64)
+		if err != nil || sec < 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'interval'"))
+			return
+		}
+		interval = time.Millisecond * time.Duration(sec*float64(time.Second/time.Millisecond))
+	}
+	if interval > DelayMax {
+		interval = DelayMax
+	}
+	disableCompression(r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flush := func() {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
 		}
 	}
+	flush() // send the headers right away
+	for i := 0; i < n; i++ {
+		if !sleepContext(r, interval) {
+			return // client went away
+		}
+		io.WriteString(w, ": heartbeat\n\n")
+		flush()
+	}
+	fmt.Fprintf(w, "data: {\"heartbeats\": %d}\n\n", n)
+	flush()
 }
 
-// DelayHandler delays responding for min(n, 10) seconds and responds
-// with /get endpoint
-func DelayHandler(w http.ResponseWriter, r *http.Request) {
-	n, _ := strconv.ParseFloat(mux.Vars(r)["n"], 64) // shouldn't fail due to route pattern
-
-	// allow only millisecond precision
-	duration := time.Millisecond * time.Duration(n*float64(time.Second/time.Millisecond))
-	if duration > DelayMax {
-		duration = DelayMax
+// MultipartHandler returns a multipart/mixed response with the number of parts
+// given in the optional 'parts' query parameter (default 3), flushing after
+// each part.
+func MultipartHandler(w http.ResponseWriter, r *http.Request) {
+	n := 3
+	if v := r.URL.Query().Get("parts"); v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'parts'"))
+			return
+		}
 	}
-	time.Sleep(duration)
-	GetHandler(w, r)
-}
+	if n > MultipartMax {
+		n = MultipartMax
+	}
+	disableCompression(r)
 
-// StreamHandler writes a json object to a new line every second.
-func StreamHandler(w http.ResponseWriter, r *http.Request) {
-	n, _ := strconv.Atoi(mux.Vars(r)["n"]) // shouldn't fail due to route pattern
-	nl := []byte{'\n'}
-	// allow only millisecond precision
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
 	for i := 0; i < n; i++ {
-		time.Sleep(StreamInterval)
-		b, _ := json.Marshal(struct {
-			N    int       `json:"n"`
-			Time time.Time `json:"time"`
-		}{i, time.Now().UTC()})
-		w.Write(b)
-		w.Write(nl)
+		var ct, body string
+		switch i % 3 {
+		case 0:
+			ct, body = "text/plain; charset=utf-8", fmt.Sprintf("part %d", i)
+		case 1:
+			ct, body = "application/json", fmt.Sprintf(`{"part": %d}`, i)
+		case 2:
+			ct, body = "text/html; charset=utf-8", fmt.Sprintf("<p>part %d</p>", i)
+		}
+		pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {ct}})
+		if err != nil {
+			return // response already started, nothing to report
+		}
+		io.WriteString(pw, body)
 		if f, ok := w.(http.Flusher); ok {
 			f.Flush()
 		}
 	}
+	mw.Close()
 }
 
 // CookiesHandler returns the cookies provided in the request.
@@ -348,8 +2451,119 @@ func DeleteCookiesHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusFound)
 }
 
+// SetManyCookiesHandler sets min('count', CookiesMax) cookies named c0, c1,
+// ... in the response and returns a 302 redirect to /cookies.
+func SetManyCookiesHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || n < 0 {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'count'"))
+		return
+	}
+	if n > CookiesMax {
+		n = CookiesMax
+	}
+	for i := 0; i < n; i++ {
+		http.SetCookie(w, &http.Cookie{
+			Name:  fmt.Sprintf("c%d", i),
+			Value: strconv.Itoa(i),
+			Path:  "/",
+		})
+	}
+	w.Header().Set("Location", "/cookies")
+	w.WriteHeader(http.StatusFound)
+}
+
+// ClearCookiesHandler deletes all cookies provided in the request by
+// expiring them in the response and returns a 302 redirect to /cookies.
+func ClearCookiesHandler(w http.ResponseWriter, r *http.Request) {
+	for _, c := range r.Cookies() {
+		http.SetCookie(w, &http.Cookie{
+			Name:    c.Name,
+			Value:   "",
+			Path:    "/",
+			Expires: time.Unix(0, 0),
+			MaxAge:  -1,
+		})
+	}
+	w.Header().Set("Location", "/cookies")
+	w.WriteHeader(http.StatusFound)
+}
+
+// ValidateCookiesHandler parses the Cookie headers of the request itself and
+// reports for each cookie whether its name and value conform to RFC 6265
+// section 4.2.1, and whether net/http accepted it, which it does leniently
+// for some invalid values and not at all for others.
+func ValidateCookiesHandler(w http.ResponseWriter, r *http.Request) {
+	accepted := make(map[string]bool)
+	for _, c := range r.Cookies() {
+		accepted[c.Name] = true
+	}
+
+	v := validateCookiesResponse{Valid: true, Cookies: []cookieValidity{}}
+	for _, h := range r.Header["Cookie"] {
+		for _, pair := range strings.Split(h, ";") {
+			pair = strings.TrimLeft(pair, " ")
+			if pair == "" {
+				continue
+			}
+			c := cookieValidity{Name: pair}
+			i := strings.IndexByte(pair, '=')
+			switch {
+			case i < 0:
+				c.Error = "missing '='"
+			case !isToken(pair[:i]):
+				c.Name, c.Value = pair[:i], pair[i+1:]
+				c.Error = "name is not a token"
+			default:
+				c.Name, c.Value = pair[:i], pair[i+1:]
+				if err := checkCookieValue(c.Value); err != "" {
+					c.Error = "value " + err
+				}
+			}
+			c.Valid = c.Error == ""
+			c.Accepted = accepted[c.Name]
+			v.Valid = v.Valid && c.Valid
+			v.Cookies = append(v.Cookies, c)
+		}
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// checkCookieValue returns why v is not a valid RFC 6265 cookie-value, or an
+// empty string if it is.
+func checkCookieValue(v string) string {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		v = v[1 : len(v)-1]
+	} else if strings.HasPrefix(v, `"`) || strings.HasSuffix(v, `"`) {
+		return "has unbalanced quotes"
+	}
+	for i := 0; i < len(v); i++ {
+		switch b := v[i]; {
+		case b < 0x20 || b == 0x7f:
+			return "contains a control character"
+		case b >= 0x80:
+			return "contains a non-ASCII byte"
+		case b == ' ', b == '"', b == ',', b == ';', b == '\\':
+			return fmt.Sprintf("contains %q", b)
+		}
+	}
+	return ""
+}
+
 // DripHandler drips data over a duration after an optional initial delay,
 // then optionally returns with the given status code.
+//
+// A single byte range in the Range header is honored with a 206 Partial
+// Content response: the bytes before the range are skipped and the rest is
+// dripped over the proportional remainder of the duration. The 'offset' query
+// parameter skips bytes for clients not sending Range, with a 200 response,
+// dripping the rest over the full duration.
+//
+// With 'trailers=1' the X-Bytes-Sent and X-Elapsed-Ms trailers summarize the
+// transfer. Trailers require chunked encoding, so Content-Length is omitted
+// in that case.
 func DripHandler(w http.ResponseWriter, r *http.Request) {
 	var retCode int
 
@@ -357,6 +2571,7 @@ func DripHandler(w http.ResponseWriter, r *http.Request) {
 	delayStr := r.URL.Query().Get("delay")
 	durationSec, _ := strconv.ParseFloat(mux.Vars(r)["duration"], 32) // shouldn't fail due to route pattern
 	numBytes, _ := strconv.Atoi(mux.Vars(r)["numbytes"])              // shouldn't fail due to route pattern
+	disableCompression(r)
 
 	if retCodeStr != "" { // optional: status code
 		var err error
@@ -365,8 +2580,45 @@ func DripHandler(w http.ResponseWriter, r *http.Request) {
 			writeErrorJSON(w, errors.New("failed to parse 'code'"))
 			return
 		}
+	}
+
+	start, end := 0, numBytes-1
+	dripped := numBytes // bytes the duration is spread over
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" { // optional: resume without Range
+		var err error
+		start, err = strconv.Atoi(offsetStr)
+		if err != nil || start < 0 || start > numBytes {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("'offset' must be between 0 and 'numbytes'"))
+			return
+		}
+		if r.Header.Get("Range") != "" {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("'offset' can't be combined with a Range header"))
+			return
+		}
+		dripped = numBytes - start
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	if rangeStr := r.Header.Get("Range"); rangeStr != "" { // optional: resume
+		var err error
+		start, end, err = parseByteRange(rangeStr, numBytes)
+		if err != nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", numBytes))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, numBytes))
+		retCode = http.StatusPartialContent
+	}
+	trailers := r.URL.Query().Get("trailers") == "1"
+	if trailers {
+		w.Header().Set("Trailer", "X-Bytes-Sent, X-Elapsed-Ms")
+	} else {
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+	}
+	if retCode != 0 {
 		w.WriteHeader(retCode)
 	}
+	began := time.Now()
 
 	if delayStr != "" { // optional: initial delay
 		delaySec, err := strconv.ParseFloat(r.URL.Query().Get("delay"), 64)
@@ -378,14 +2630,23 @@ func DripHandler(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(delayMs * time.Millisecond)
 	}
 
-	t := time.Second * time.Duration(durationSec) / time.Duration(numBytes)
-	for i := 0; i < numBytes; i++ {
-		w.Write([]byte{'*'})
+	var t time.Duration
+	if dripped > 0 {
+		t = time.Duration(durationSec*float64(time.Second)) / time.Duration(dripped)
+	}
+	sent := 0
+	for i := start; i <= end; i++ {
+		n, _ := w.Write([]byte{'*'})
+		sent += n
 		if f, ok := w.(http.Flusher); ok {
 			f.Flush()
 		}
 		time.Sleep(t)
 	}
+	if trailers {
+		w.Header().Set("X-Bytes-Sent", strconv.Itoa(sent))
+		w.Header().Set("X-Elapsed-Ms", strconv.FormatInt(int64(time.Since(began)/time.Millisecond), 10))
+	}
 }
 
 // CacheHandler returns 200 with the response of /get unless an If-Modified-Since
@@ -398,6 +2659,34 @@ func CacheHandler(w http.ResponseWriter, r *http.Request) {
 	GetHandler(w, r)
 }
 
+// CanonicalizeETagHandler parses the entity-tag in the 'etag' query parameter
+// and returns its canonical form, whether it is weak, and the comparison it
+// can take part in: weak tags only ever match with the weak comparison of
+// RFC 7232, section 2.3.2. A malformed entity-tag is responded with 400.
+func CanonicalizeETagHandler(w http.ResponseWriter, r *http.Request) {
+	in := r.URL.Query().Get("etag")
+	weak, opaque, canonical, err := parseETag(strings.TrimSpace(in))
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, err)
+		return
+	}
+	v := etagResponse{
+		Input:      in,
+		ETag:       `"` + opaque + `"`,
+		Weak:       weak,
+		Opaque:     opaque,
+		Comparison: "strong",
+		Canonical:  canonical && in == strings.TrimSpace(in),
+	}
+	if weak {
+		v.ETag = "W/" + v.ETag
+		v.Comparison = "weak"
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
 // SetCacheHandler sets a Cache-Control header for n seconds and returns with
 // the /get response.
 func SetCacheHandler(w http.ResponseWriter, r *http.Request) {
@@ -406,6 +2695,41 @@ func SetCacheHandler(w http.ResponseWriter, r *http.Request) {
 	GetHandler(w, r)
 }
 
+// VaryHandler lists the request headers named in the comma-separated 'on'
+// query parameter in the Vary header and returns their values, along with a
+// variant identifier derived from them, so that a cache keying responses
+// correctly serves a different body for each combination of values.
+func VaryHandler(w http.ResponseWriter, r *http.Request) {
+	var names []string
+	for _, n := range strings.Split(r.URL.Query().Get("on"), ",") {
+		if n = strings.TrimSpace(n); n == "" {
+			continue
+		}
+		if !isToken(n) {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.Errorf("invalid header name %q", n))
+			return
+		}
+		names = append(names, http.CanonicalHeaderKey(n))
+	}
+	if len(names) == 0 {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("'on' is required"))
+		return
+	}
+
+	v := varyResponse{Vary: names, Headers: make(map[string]string, len(names))}
+	h := sha256.New()
+	for _, n := range names {
+		v.Headers[n] = strings.Join(r.Header[n], ", ")
+		fmt.Fprintf(h, "%s: %s\n", n, v.Headers[n])
+	}
+	v.Variant = hex.EncodeToString(h.Sum(nil))[:16]
+
+	w.Header().Set("Vary", strings.Join(names, ", "))
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
 // GZIPHandler returns a GZIP-encoded response
 func GZIPHandler(w http.ResponseWriter, r *http.Request) {
 	h, _, _ := net.SplitHostPort(r.RemoteAddr)
@@ -416,13 +2740,65 @@ func GZIPHandler(w http.ResponseWriter, r *http.Request) {
 		Gzipped:         true,
 	}
 
+	var buf bytes.Buffer
+	if err := writeJSON(keyStyleOf(w, &buf), v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Add("Content-Encoding", "gzip")
 	ww := gzip.NewWriter(w)
 	defer ww.Close() // flush
-	if err := writeJSON(ww, v); err != nil {
+	buf.WriteTo(ww)
+}
+
+// MaybeGZIPHandler returns the /get response GZIP-encoded only if it is
+// larger than the 'min' query parameter (default 1024) bytes, otherwise it
+// is returned as is.
+func MaybeGZIPHandler(w http.ResponseWriter, r *http.Request) {
+	min := 1024
+	if v := r.URL.Query().Get("min"); v != "" {
+		var err error
+		min, err = strconv.Atoi(v)
+		if err != nil || min < 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'min'"))
+			return
+		}
+	}
+
+	h, _, _ := net.SplitHostPort(r.RemoteAddr)
+	disableCompression(r) // compression is decided here
+	v := maybeGzipResponse{
+		getResponse: getResponse{
+			headersResponse: headersResponse{getHeaders(r)},
+			ipResponse:      ipResponse{h},
+			URL:             requestURL(r),
+			Args:            flattenValues(r.URL.Query()),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSON(keyStyleOf(w, &buf), v); err != nil {
 		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if buf.Len() <= min {
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		buf.WriteTo(w)
+		return
+	}
+
+	v.Gzipped = true
+	buf.Reset()
+	if err := writeJSON(keyStyleOf(w, &buf), v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+		return
 	}
+	w.Header().Add("Content-Encoding", "gzip")
+	ww := gzip.NewWriter(w)
+	defer ww.Close() // flush
+	buf.WriteTo(ww)
 }
 
 // DeflateHandler returns a DEFLATE-encoded response.
@@ -435,41 +2811,124 @@ func DeflateHandler(w http.ResponseWriter, r *http.Request) {
 		Deflated:        true,
 	}
 
+	var buf bytes.Buffer
+	if err := writeJSON(keyStyleOf(w, &buf), v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+		return
+	}
 	w.Header().Set("Content-Encoding", "deflate")
 	ww, _ := flate.NewWriter(w, flate.BestCompression)
 	defer ww.Close() // flush
-	if err := writeJSON(ww, v); err != nil {
-		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
-	}
+	buf.WriteTo(ww)
 }
 
-// BrotliHandler returns a Brotli-encoded response
+// BrotliHandler returns a Brotli-encoded response. The encoder quality (0-11,
+// default 6) and base 2 logarithm of the window size (10-24, or 0 for the
+// default based on quality) can be set with the 'quality' and 'window' query
+// parameters.
 func BrotliHandler(w http.ResponseWriter, r *http.Request) {
 	h, _, _ := net.SplitHostPort(r.RemoteAddr)
 
+	q := r.URL.Query()
+	opts := brotli.WriterOptions{Quality: brotli.DefaultCompression}
+	if v := q.Get("quality"); v != "" {
+		var err error
+		if opts.Quality, err = strconv.Atoi(v); err != nil || opts.Quality < brotli.BestSpeed || opts.Quality > brotli.BestCompression {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.Errorf("quality must be between %d and %d", brotli.BestSpeed, brotli.BestCompression))
+			return
+		}
+	}
+	if v := q.Get("window"); v != "" {
+		var err error
+		if opts.LGWin, err = strconv.Atoi(v); err != nil || opts.LGWin != 0 && (opts.LGWin < 10 || opts.LGWin > 24) {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("window must be 0 or between 10 and 24"))
+			return
+		}
+	}
+
 	v := brotliResponse{
 		headersResponse: headersResponse{getHeaders(r)},
 		ipResponse:      ipResponse{h},
 		Compressed:      true,
+		Quality:         opts.Quality,
+		Window:          opts.LGWin,
 	}
 
+	var buf bytes.Buffer
+	if err := writeJSON(keyStyleOf(w, &buf), v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Add("Content-Encoding", "br")
-	ww := brotli.NewWriter(w)
+	ww := brotli.NewWriterOptions(w, opts)
 	defer ww.Close() // flush
-	if err := writeJSON(ww, v); err != nil {
+	buf.WriteTo(ww)
+}
+
+var (
+	greetings = []struct {
+		lang     language.Tag
+		greeting string
+	}{
+		{language.English, "Hello"}, // default
+		{language.Spanish, "Hola"},
+		{language.French, "Bonjour"},
+		{language.German, "Hallo"},
+		{language.Japanese, "こんにちは"},
+		{language.Turkish, "Merhaba"},
+	}
+
+	greetingMatcher = func() language.Matcher {
+		tags := make([]language.Tag, len(greetings))
+		for i, g := range greetings {
+			tags[i] = g.lang
+		}
+		return language.NewMatcher(tags)
+	}()
+)
+
+// GreetingHandler returns a greeting in the language that best matches the
+// Accept-Language header, defaulting to English.
+func GreetingHandler(w http.ResponseWriter, r *http.Request) {
+	tags, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language")) // malformed header falls back to default
+	_, i, _ := greetingMatcher.Match(tags...)
+	g := greetings[i]
+
+	w.Header().Set("Content-Language", g.lang.String())
+	w.Header().Set("Vary", "Accept-Language")
+	if err := writeJSON(w, greetingResponse{Language: g.lang.String(), Greeting: g.greeting}); err != nil {
 		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
 	}
 }
 
-// RobotsTXTHandler returns a robots.txt response.
+// RobotsTXTHandler returns a robots.txt response, or its JSON representation
+// if the client prefers JSON.
 func RobotsTXTHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	if negotiateType(r, "text/plain", "application/json") == "application/json" {
+		w.Header().Set("Content-Type", "application/json")
+		v := robotsResponse{UserAgent: "*", Disallow: []string{"/deny"}}
+		if err := writeJSON(w, v); err != nil {
+			writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+		}
+		return
+	}
 	w.Header().Set("Content-Type", "text/plain")
 	fmt.Fprint(w, "User-agent: *\nDisallow: /deny\n")
 }
 
-// DenyHandler returns a plain-text response.
+// DenyHandler returns a plain-text response, or a JSON message if the client
+// prefers JSON.
 func DenyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	if negotiateType(r, "text/plain", "application/json") == "application/json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := writeJSON(w, messageResponse{"YOU SHOULDN'T BE HERE"}); err != nil {
+			writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+		}
+		return
+	}
 	w.Header().Set("Content-Type", "text/plain")
 	fmt.Fprint(w, `
           .-''''''-.
@@ -485,6 +2944,13 @@ func DenyHandler(w http.ResponseWriter, r *http.Request) {
 `)
 }
 
+// MalformedJSONHandler intentionally returns a truncated, invalid JSON body
+// with a JSON Content-Type, to test how clients handle a misbehaving server.
+func MalformedJSONHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"incomplete":`)
+}
+
 // BasicAuthHandler challenges with given username and password.
 func BasicAuthHandler(w http.ResponseWriter, r *http.Request) {
 	basicAuthHandler(w, r, http.StatusUnauthorized)
@@ -514,18 +2980,113 @@ func basicAuthHandler(w http.ResponseWriter, r *http.Request, status int) {
 	}
 }
 
+// token68 matches credentials in the token68 syntax of RFC 7235, which Bearer
+// tokens use.
+var token68 = regexp.MustCompile(`^[A-Za-z0-9\-._~+/]+=*$`)
+
+// BearerHandler accepts any token sent with the Bearer scheme of RFC 6750 and
+// returns it, with whether it is in the token68 syntax. Requests without one
+// are challenged with 401 and a WWW-Authenticate: Bearer header.
+func BearerHandler(w http.ResponseWriter, r *http.Request) {
+	v := parseAuthorization(r.Header.Get("Authorization"))
+	if !strings.EqualFold(v.Scheme, "bearer") || v.Credentials == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err := writeJSON(w, bearerResponse{Authenticated: true, Token: v.Credentials, ValidFormat: *v.ValidFormat}); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// AuthEchoHandler returns the structure of the Authorization header without
+// checking the credentials: its scheme and credentials, decoded into user and
+// password for Basic, and whether they are well-formed for the scheme.
+func AuthEchoHandler(w http.ResponseWriter, r *http.Request) {
+	var v authEchoResponse
+	if h := r.Header.Get("Authorization"); h != "" {
+		v = parseAuthorization(h)
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+func parseAuthorization(h string) authEchoResponse {
+	v := authEchoResponse{Present: true}
+	v.Scheme = h
+	if i := strings.IndexByte(h, ' '); i >= 0 {
+		v.Scheme, v.Credentials = h[:i], strings.TrimLeft(h[i+1:], " ")
+	}
+
+	valid := isToken(v.Scheme)
+	switch strings.ToLower(v.Scheme) {
+	case "basic":
+		b, err := base64.StdEncoding.DecodeString(v.Credentials)
+		i := bytes.IndexByte(b, ':')
+		if err != nil || i < 0 {
+			valid = false
+			break
+		}
+		user, pass := string(b[:i]), string(b[i+1:])
+		v.User, v.Password = &user, &pass
+	case "bearer":
+		valid = valid && token68.MatchString(v.Credentials)
+	}
+	v.ValidFormat = &valid
+	return v
+}
+
+// isToken reports whether s is a non-empty RFC 7230 token.
+func isToken(s string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool { return !isTokenChar(r) }) < 0
+}
+
+// isTokenChar reports whether r may appear in an RFC 7230 token.
+func isTokenChar(r rune) bool {
+	return r < 0x7f && r > ' ' && !strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r)
+}
+
+// AcceptParseHandler returns the media ranges of the Accept header as parsed
+// by the server, sorted by preference, or 400 naming the first malformed one.
+func AcceptParseHandler(w http.ResponseWriter, r *http.Request) {
+	accept := strings.Join(r.Header["Accept"], ", ")
+	entries, err := parseAccept(accept)
+	if err != nil {
+		writeErrorJSONStatus(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := writeJSON(w, acceptParseResponse{Accept: accept, Entries: entries}); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
 // HTMLHandler returns some HTML response.
 func HTMLHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
 	fmt.Fprint(w, htmlData)
 }
 
+// JSONHandler returns the slideshow of XMLHandler as JSON.
+func JSONHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeJSON(w, sampleSlideshow); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
 // XMLHandler returns some XML response.
 func XMLHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/xml")
 	fmt.Fprint(w, xmlData)
 }
 
+// YAMLHandler returns the slideshow of XMLHandler as YAML.
+func YAMLHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(sampleSlideshow.marshalYAML())
+}
+
 type circle struct {
 	X, Y, R float64
 }
@@ -539,10 +3100,15 @@ func (c *circle) Brightness(x, y float64) uint8 {
 	return 255
 }
 
-// GIFHandler returns an animated GIF image.
+// GIFHandler returns an animated GIF image, at half the size for clients
+// sending Save-Data: on.
 // Source: http://tech.nitoyon.com/en/blog/2016/01/07/go-animated-gif-gen/
 func GIFHandler(rw http.ResponseWriter, r *http.Request) {
-	var w, h int = 240, 240
+	scale := 1.0
+	if saveData(rw, r) {
+		scale = 0.5
+	}
+	var w, h int = int(240 * scale), int(240 * scale)
 	var hw, hh float64 = float64(w / 2), float64(h / 2)
 	circles := []*circle{{}, {}, {}}
 
@@ -568,9 +3134,9 @@ func GIFHandler(rw http.ResponseWriter, r *http.Request) {
 		θ := 2.0 * math.Pi / float64(steps) * float64(step)
 		for i, circle := range circles {
 			θ0 := 2 * math.Pi / 3 * float64(i)
-			circle.X = hw - 40*math.Sin(θ0) - 20*math.Sin(θ0+θ)
-			circle.Y = hh - 40*math.Cos(θ0) - 20*math.Cos(θ0+θ)
-			circle.R = 50
+			circle.X = hw - scale*(40*math.Sin(θ0)+20*math.Sin(θ0+θ))
+			circle.Y = hh - scale*(40*math.Cos(θ0)+20*math.Cos(θ0+θ))
+			circle.R = 50 * scale
 		}
 
 		for x := 0; x < w; x++ {
@@ -585,24 +3151,115 @@ func GIFHandler(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	gif.EncodeAll(rw, &gif.GIF{
+	var buf bytes.Buffer
+	gif.EncodeAll(&buf, &gif.GIF{
 		Image: images,
 		Delay: delays,
 	})
+	serveContent(rw, r, imageLastModified, buf.Bytes())
 }
 
-// JPEGHandler returns a JPEG image.
+// JPEGHandler returns a JPEG image, at a quarter of the size for clients
+// sending Save-Data: on.
 func JPEGHandler(w http.ResponseWriter, r *http.Request) {
-	jpeg.Encode(w, getImg(), nil)
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, getImg(imageSize(w, r)), nil)
+	serveContent(w, r, imageLastModified, buf.Bytes())
 }
 
-// PNGHandler returns a PNG image.
+// PNGHandler returns a PNG image, at a quarter of the size for clients
+// sending Save-Data: on.
 func PNGHandler(w http.ResponseWriter, r *http.Request) {
-	png.Encode(w, getImg())
+	var buf bytes.Buffer
+	png.Encode(&buf, getImg(imageSize(w, r)))
+	serveContent(w, r, imageLastModified, buf.Bytes())
+}
+
+// imageSize returns the width and height of the images from getImg.
+func imageSize(w http.ResponseWriter, r *http.Request) int {
+	if saveData(w, r) {
+		return 128
+	}
+	return 512
+}
+
+// imageTextMax is the maximum number of characters drawn by /image/text.
+const imageTextMax = 64
+
+// textColors are the colors /image/text picks from for the text.
+var textColors = []color.RGBA{
+	{0x00, 0x00, 0x00, 0xff},
+	{0x80, 0x00, 0x00, 0xff},
+	{0x00, 0x60, 0x00, 0xff},
+	{0x00, 0x00, 0x80, 0xff},
+}
+
+// TextImageHandler returns the PNG image of PNGHandler with the text given in
+// the 'content' query parameter (default "httpbin", at most imageTextMax
+// characters) drawn on a white band across it, scaled up as far as it fits.
+// The text color and position are picked with the optional 'seed' integer
+// parameter (default 0), so the image is reproducible.
+func TextImageHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	text := q.Get("content")
+	if text == "" {
+		text = "httpbin"
+	}
+	if rs := []rune(text); len(rs) > imageTextMax {
+		text = string(rs[:imageTextMax])
+	}
+	var seed int64
+	if v := q.Get("seed"); v != "" {
+		var err error
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'seed'"))
+			return
+		}
+	}
+	rnd := rand.New(rand.NewSource(seed))
+
+	// draw the text at its original size, then scale it up onto the image
+	face := basicfont.Face7x13
+	d := &font.Drawer{Src: image.Opaque, Face: face}
+	tw, th := d.MeasureString(text).Ceil(), face.Height
+	mask := image.NewAlpha(image.Rect(0, 0, tw, th))
+	d.Dst = mask
+	d.Dot = fixed.P(0, face.Ascent)
+	d.DrawString(text)
+
+	const n, margin = 512, 16
+	scale := 1
+	if tw > 0 {
+		scale = (n - 2*margin) / tw
+	}
+	if scale > 4 {
+		scale = 4
+	} else if scale < 1 {
+		scale = 1
+	}
+	x0 := (n - tw*scale) / 2
+	y0 := margin + rnd.Intn(n-2*margin-th*scale+1)
+	fg := image.NewUniform(textColors[rnd.Intn(len(textColors))])
+
+	img := getImg(n)
+	band := image.Rect(0, y0-margin/2, n, y0+th*scale+margin/2)
+	draw.Draw(img, band, image.White, image.Point{}, draw.Src)
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			if mask.AlphaAt(x, y).A >= 0x80 {
+				px := image.Rect(x0+x*scale, y0+y*scale, x0+(x+1)*scale, y0+(y+1)*scale)
+				draw.Draw(img, px, fg, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	w.Header().Set("Content-Type", "image/png")
+	serveContent(w, r, time.Time{}, buf.Bytes())
 }
 
-func getImg() image.Image {
-	const n = 512
+func getImg(n int) *image.RGBA {
 	img := image.NewRGBA(image.Rect(0, 0, n, n))
 	abs := func(n int) int {
 		if n < 0 {
@@ -618,7 +3275,7 @@ func getImg() image.Image {
 				continue
 			}
 			d := math.Sqrt(float64(sq(abs(x-n/2)) + sq(abs(y-n/2))))
-			if d > n/2 {
+			if d > float64(n/2) {
 				continue
 			}
 
@@ -667,16 +3324,74 @@ func getImg() image.Image {
 	return img
 }
 
+// contentEncodingError reports a request body that could not be decoded
+// according to its Content-Encoding header.
+type contentEncodingError struct {
+	encoding string
+	err      error
+}
+
+func (e *contentEncodingError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("unsupported Content-Encoding %q", e.encoding)
+	}
+	return fmt.Sprintf("failed to decode body with Content-Encoding %q: %v", e.encoding, e.err)
+}
+
+// parseData reads the request body, decoding it according to its
+// Content-Encoding, up to MaxBodySize decoded bytes.
 func parseData(r *http.Request) ([]byte, error) {
 	if r.Body == nil {
 		return nil, nil
 	}
 	defer r.Body.Close()
 
-	data, err := ioutil.ReadAll(r.Body)
+	var body io.Reader = r.Body
+	switch enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, &contentEncodingError{enc, err}
+		}
+		body = zr
+	case "deflate":
+		body = flate.NewReader(r.Body)
+	case "br":
+		body = brotli.NewReader(r.Body)
+	default:
+		return nil, &contentEncodingError{encoding: enc}
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(body, MaxBodySize+1))
 	if err != nil {
+		if enc := r.Header.Get("Content-Encoding"); enc != "" {
+			return nil, &contentEncodingError{enc, err}
+		}
 		return nil, err
 	}
+	if int64(len(data)) > MaxBodySize {
+		return nil, errBodyTooLarge
+	}
 
 	return data, nil
 }
+
+// writeBodyError writes the error returned by parseData with a matching
+// status code.
+func writeBodyError(w http.ResponseWriter, err error) {
+	switch e := errors.Cause(err).(type) {
+	case *contentEncodingError:
+		if e.err == nil {
+			writeErrorJSONStatus(w, http.StatusUnsupportedMediaType, e)
+		} else {
+			writeErrorJSONStatus(w, http.StatusBadRequest, e)
+		}
+	default:
+		if e == errBodyTooLarge {
+			writeErrorJSONStatus(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		writeErrorJSON(w, errors.Wrap(err, "failed to read body"))
+	}
+}
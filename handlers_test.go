@@ -1,28 +1,50 @@
 package httpbin_test
 
 import (
+	"bufio"
 	"bytes"
 	"compress/flate"
 	"compress/gzip"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ahmetb/go-httpbin"
 	"github.com/andybalholm/brotli"
 	"github.com/stretchr/testify/require"
 
 	"golang.org/x/net/html/charset"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var (
@@ -93,6 +115,234 @@ func assertLocationHeader(t *testing.T, u, expected string) {
 	require.Equal(t, expected, resp.Header.Get("Location"), u)
 }
 
+func TestDefaultHeaders(t *testing.T) {
+	srv := httptest.NewServer(httpbin.GetMux(httpbin.WithDefaultHeaders(http.Header{
+		"X-Test-Env":   {"staging"},
+		"Content-Type": {"application/x-default"},
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/robots.txt")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "staging", resp.Header.Get("X-Test-Env"))
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type")) // set by handler
+}
+
+func TestDebugStats(t *testing.T) {
+	srv := testServer()
+	resp, err := http.Get(srv.URL + "/debug/stats")
+	require.Nil(t, err)
+	resp.Body.Close()
+	srv.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	srv = httptest.NewServer(httpbin.GetMux(httpbin.WithDebugStats()))
+	defer srv.Close()
+
+	var v struct {
+		Goroutines int    `json:"goroutines"`
+		HeapAlloc  uint64 `json:"heap_alloc"`
+	}
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+"/debug/stats"), &v))
+	require.True(t, v.Goroutines > 0)
+	require.True(t, v.HeapAlloc > 0)
+}
+
+func TestRequestRecording(t *testing.T) {
+	srv := testServer()
+	resp, err := http.Get(srv.URL + "/requests")
+	require.Nil(t, err)
+	resp.Body.Close()
+	srv.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	srv = httptest.NewServer(httpbin.GetMux(httpbin.WithRequestRecording(2)))
+	defer srv.Close()
+
+	type recorded struct {
+		Method        string      `json:"method"`
+		URL           string      `json:"url"`
+		Headers       http.Header `json:"headers"`
+		Body          string      `json:"body"`
+		BodyTruncated bool        `json:"body_truncated"`
+	}
+	list := func() []recorded {
+		var v struct {
+			Requests []recorded `json:"requests"`
+		}
+		require.Nil(t, json.Unmarshal(get(t, srv.URL+"/requests"), &v))
+		return v.Requests
+	}
+	require.Empty(t, list())
+
+	get(t, srv.URL+"/get?a=1")
+	resp, err = http.Post(srv.URL+"/post", "text/plain", strings.NewReader("hello"))
+	require.Nil(t, err)
+	resp.Body.Close()
+	resp, err = http.Post(srv.URL+"/status/200", "text/plain", strings.NewReader(strings.Repeat("x", 2000)))
+	require.Nil(t, err)
+	resp.Body.Close()
+
+	v := list()
+	require.Len(t, v, 2) // the first one fell out of the buffer
+	require.Equal(t, "POST", v[0].Method)
+	require.Equal(t, "/post", v[0].URL)
+	require.Equal(t, "text/plain", v[0].Headers.Get("Content-Type"))
+	require.Equal(t, "hello", v[0].Body)
+	require.False(t, v[0].BodyTruncated)
+	require.Equal(t, "/status/200", v[1].URL)
+	require.Equal(t, strings.Repeat("x", 1024), v[1].Body) // not read by the handler
+	require.True(t, v[1].BodyTruncated)
+
+	// requests not matching any route are recorded too
+	resp, err = http.Get(srv.URL + "/no-such")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, err = http.Get(srv.URL + "/post")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	v = list()
+	require.Len(t, v, 2)
+	require.Equal(t, "/no-such", v[0].URL)
+	require.Equal(t, "GET", v[1].Method)
+	require.Equal(t, "/post", v[1].URL)
+
+	resp, err = http.Post(srv.URL+"/requests/clear", "", nil)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Empty(t, list())
+}
+
+func TestResponseTime(t *testing.T) {
+	srv := httptest.NewServer(httpbin.GetMux(httpbin.WithResponseTime()))
+	defer srv.Close()
+
+	for _, path := range []string{"/delay/0.1", "/no-content"} {
+		resp, err := http.Get(srv.URL + path)
+		require.Nil(t, err)
+		resp.Body.Close()
+		v := resp.Header.Get("X-Response-Time")
+		require.NotEmpty(t, v, path)
+		ms, err := strconv.ParseFloat(v, 64)
+		require.Nil(t, err)
+		require.True(t, ms >= 0, path)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	srv := httptest.NewServer(httpbin.GetMux(httpbin.WithCompression()))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/get", nil)
+	req.Header.Set("Accept-Encoding", "gzip") // disable transparent decompression
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	zr, err := gzip.NewReader(resp.Body)
+	require.Nil(t, err)
+	var v map[string]interface{}
+	require.Nil(t, json.NewDecoder(zr).Decode(&v))
+	require.NotEmpty(t, v["headers"])
+}
+
+func TestCompression_weights(t *testing.T) {
+	srv := httptest.NewServer(httpbin.GetMux(httpbin.WithCompression()))
+	defer srv.Close()
+
+	for accept, want := range map[string]string{
+		"gzip":                 "gzip",
+		"GZIP;q=0.5, br":       "gzip",
+		"*":                    "gzip",
+		"gzip;q=0":             "",
+		"gzip;q=0.000, *":      "",
+		"*;q=0":                "",
+		"br, *;q=0":            "",
+		"gzip;q=0, *;q=1":      "",
+		"gzip;q=0.1, *;q=0":    "gzip",
+		"gzip;q=x":             "",
+		"identity, deflate":    "",
+		"deflate;q=1, gzip;q=": "",
+	} {
+		req, _ := http.NewRequest("GET", srv.URL+"/get", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, want, resp.Header.Get("Content-Encoding"), accept)
+	}
+}
+
+func TestCompression_dripNotBuffered(t *testing.T) {
+	srv := httptest.NewServer(httpbin.GetMux(httpbin.WithCompression()))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/drip?numbytes=5&duration=1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	buf := make([]byte, 1)
+	_, err = io.ReadFull(resp.Body, buf)
+	require.Nil(t, err)
+	first := time.Since(start)
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	total := time.Since(start)
+
+	require.Equal(t, "****", string(b))
+	require.True(t, first < 500*time.Millisecond, "first byte after %v", first)
+	require.True(t, total >= 800*time.Millisecond, "all bytes after %v", total)
+}
+
+func TestJSONKeyStyle(t *testing.T) {
+	cases := []struct {
+		style httpbin.JSONKeyStyle
+		key   string
+	}{
+		{httpbin.JSONKeyDefault, "user-agent"},
+		{httpbin.JSONKeySnake, "user_agent"},
+		{httpbin.JSONKeyCamel, "userAgent"},
+	}
+	for _, c := range cases {
+		srv := httptest.NewServer(httpbin.GetMux(httpbin.WithJSONKeyStyle(c.style)))
+		b := get(t, srv.URL+"/user-agent")
+		srv.Close()
+
+		var v map[string]interface{}
+		require.Nil(t, json.Unmarshal(b, &v))
+		require.Contains(t, v, c.key)
+		require.Len(t, v, 1)
+	}
+
+	// keys carrying request data are left as is
+	srv := httptest.NewServer(httpbin.GetMux(httpbin.WithJSONKeyStyle(httpbin.JSONKeyCamel)))
+	defer srv.Close()
+	b := get(t, srv.URL+"/get?some_arg=1")
+	var v struct {
+		Args    map[string]interface{} `json:"args"`
+		Headers map[string]string      `json:"headers"`
+	}
+	require.Nil(t, json.Unmarshal(b, &v))
+	require.Contains(t, v.Args, "some_arg")
+	require.Contains(t, v.Headers, "User-Agent")
+
+	// the compressed responses are spelled alike
+	for _, path := range []string{"/gzip", "/maybe-gzip?min=0&some_arg=1"} {
+		var v map[string]interface{}
+		require.Nil(t, json.Unmarshal(get(t, srv.URL+path), &v), path)
+		require.Equal(t, true, v["gzipped"], path)
+		require.Contains(t, v["headers"], "User-Agent", path)
+	}
+}
+
 func TestHome(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -113,6 +363,97 @@ func TestIP(t *testing.T) {
 	require.Equal(t, "127.0.0.1", v.Origin)
 }
 
+func TestHostname(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	want, _ := os.Hostname()
+	b := get(t, srv.URL+"/hostname")
+	var v struct {
+		Hostname string `json:"hostname"`
+	}
+	require.Nil(t, json.Unmarshal(b, &v))
+	require.Equal(t, want, v.Hostname)
+}
+
+func TestIP_plainText(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/ip", nil)
+	req.Header.Set("Accept", "text/plain")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, "127.0.0.1\n", string(b))
+}
+
+func TestUserAgentParse(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/user-agent/parse", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	var v struct {
+		Bot bool   `json:"bot"`
+		Raw string `json:"raw"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.True(t, v.Bot)
+	require.Equal(t, req.Header.Get("User-Agent"), v.Raw)
+}
+
+func TestGeo(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type result struct {
+		Country string   `json:"country"`
+		Lat     *float64 `json:"latitude"`
+		Source  string   `json:"source"`
+	}
+	geo := func(header, value string) result {
+		req, _ := http.NewRequest("GET", srv.URL+"/geo", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		var v result
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+		return v
+	}
+
+	v := geo("CF-IPCountry", "de")
+	require.Equal(t, "DE", v.Country)
+	require.NotNil(t, v.Lat)
+	require.Equal(t, "header", v.Source)
+
+	require.Equal(t, "JP", geo("X-Country", "JP").Country)
+	require.Equal(t, result{Country: "XX"}, geo("X-Country", "ZZ"))
+	require.Equal(t, result{Country: "XX"}, geo("", "")) // 127.0.0.1 is not listed
+}
+
+func TestSelf(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	var v struct {
+		BaseURL string `json:"base_url"`
+		URL     string `json:"url"`
+	}
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+"/self?a=1"), &v))
+	require.Equal(t, srv.URL, v.BaseURL)
+	require.Equal(t, srv.URL+"/self?a=1", v.URL)
+}
+
 func TestUserAgent(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -125,157 +466,1416 @@ func TestUserAgent(t *testing.T) {
 	require.NotEmpty(t, v.UA)
 }
 
-func TestHeaders(t *testing.T) {
+func TestHTTPVersion(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
 
-	b := get(t, srv.URL+"/headers")
+	b := get(t, srv.URL+"/http-version")
 	v := struct {
-		Headers map[string]string `json:"headers"`
+		Proto      string `json:"proto"`
+		ProtoMajor int    `json:"proto_major"`
 	}{}
 	require.Nil(t, json.Unmarshal(b, &v))
-	require.NotEmpty(t, v.Headers["User-Agent"]) // provided by default Go HTTP client
+	require.Equal(t, "HTTP/1.1", v.Proto)
+	require.Equal(t, 1, v.ProtoMajor)
 }
 
-func TestGet(t *testing.T) {
-	srv := testServer()
+func TestALPN(t *testing.T) {
+	srv := httptest.NewUnstartedServer(httpbin.GetMux())
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
 	defer srv.Close()
 
-	b := get(t, srv.URL+"/get?k1=v1&k1=v2&k3=v3")
-	v := struct {
-		Args    map[string]interface{} `json:"args"`
-		Headers map[string]string      `json:"headers"`
-		Origin  string                 `json:"origin"`
-	}{}
+	type resp struct {
+		TLS                bool   `json:"tls"`
+		NegotiatedProtocol string `json:"negotiated_protocol"`
+	}
+
+	resp1, err := srv.Client().Get(srv.URL + "/alpn")
+	require.Nil(t, err)
+	defer resp1.Body.Close()
+	var v resp
+	require.Nil(t, json.NewDecoder(resp1.Body).Decode(&v))
+	require.Equal(t, resp{true, "h2"}, v)
+
+	plain := testServer()
+	defer plain.Close()
+	b := get(t, plain.URL+"/alpn")
+	v = resp{}
 	require.Nil(t, json.Unmarshal(b, &v))
-	require.NotEmpty(t, v.Args, "args empty")
-	require.EqualValues(t, map[string]interface{}{
-		"k1": []interface{}{"v1", "v2"},
-		"k3": "v3",
-	}, v.Args)
-	require.NotEmpty(t, v.Headers)
-	require.NotEmpty(t, v.Origin)
+	require.Equal(t, resp{false, ""}, v)
 }
-func TestPost(t *testing.T) {
-	srv := testServer()
-	defer srv.Close()
 
-	data := `{[{"k1": "v1"}]}`
+func TestH2Info(t *testing.T) {
+	// push support must be detected through the middlewares wrapping the writer
+	srv := httptest.NewUnstartedServer(httpbin.GetMux(httpbin.WithResponseTime()))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
 
-	b := post(t, srv.URL+"/post?k1=v1&k1=v2&k3=v3", []byte(data))
-	v := struct {
-		Args    map[string]interface{} `json:"args"`
-		Headers map[string]string      `json:"headers"`
-		Origin  string                 `json:"origin"`
-		Data    string                 `json:"data"`
-		JSON    interface{}            `json:"json"`
-	}{}
-	require.Nil(t, json.Unmarshal(b, &v))
-	require.EqualValues(t, map[string]interface{}{
-		"k1": []interface{}{"v1", "v2"},
-		"k3": "v3",
-	}, v.Args)
+	type info struct {
+		HTTP2         bool `json:"http2"`
+		PushSupported bool `json:"push_supported"`
+	}
+	resp, err := srv.Client().Get(srv.URL + "/h2-info")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	var v info
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, info{true, true}, v)
 
-	require.EqualValues(t, data, v.Data)
-	require.NotEmpty(t, v.Headers)
-	require.NotEmpty(t, v.Origin)
+	plain := httptest.NewServer(httpbin.GetMux(httpbin.WithResponseTime()))
+	defer plain.Close()
+	v = info{}
+	require.Nil(t, json.Unmarshal(get(t, plain.URL+"/h2-info"), &v))
+	require.Equal(t, info{false, false}, v)
 }
 
-func TestRedirect(t *testing.T) {
+func TestLimits(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
 
-	assertLocationHeader(t, srv.URL+"/redirect/0", "/get")
-	assertLocationHeader(t, srv.URL+"/redirect/1", "/get")
-	assertLocationHeader(t, srv.URL+"/redirect/2", "/redirect/1")
-	assertLocationHeader(t, srv.URL+"/redirect/100", "/redirect/99")
+	b := get(t, srv.URL+"/limits")
+	var v struct {
+		MaxBodySize    int64   `json:"max_body_size"`
+		MaxBytes       int     `json:"max_bytes"`
+		MaxDelay       float64 `json:"max_delay"`
+		MaxStreamCount int     `json:"max_stream_count"`
+	}
+	require.Nil(t, json.Unmarshal(b, &v))
+	require.Equal(t, httpbin.MaxBodySize, v.MaxBodySize)
+	require.Equal(t, httpbin.BytesMax, v.MaxBytes)
+	require.Equal(t, httpbin.DelayMax.Seconds(), v.MaxDelay)
+	require.Equal(t, httpbin.StreamMax, v.MaxStreamCount)
 }
 
-func TestAbsoluteRedirect(t *testing.T) {
+func TestPost_bodyTooLarge(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
 
-	assertLocationHeader(t, srv.URL+"/absolute-redirect/0", srv.URL+"/get")
-	assertLocationHeader(t, srv.URL+"/absolute-redirect/1", srv.URL+"/get")
-	assertLocationHeader(t, srv.URL+"/absolute-redirect/2", srv.URL+"/absolute-redirect/1")
-	assertLocationHeader(t, srv.URL+"/absolute-redirect/100", srv.URL+"/absolute-redirect/99")
+	defer func(v int64) { httpbin.MaxBodySize = v }(httpbin.MaxBodySize)
+	httpbin.MaxBodySize = 10
+
+	resp, err := http.Post(srv.URL+"/post", "text/plain", bytes.NewBufferString("01234567890"))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
 }
 
-func TestRedirectTo(t *testing.T) {
+func TestSpec(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
 
-	assertLocationHeader(t, srv.URL+"/redirect-to?url=ip", "ip")
-	assertLocationHeader(t, srv.URL+"/redirect-to?url=/ip", "/ip")
-	assertLocationHeader(t, srv.URL+"/redirect-to?url=http%3A%2F%2Fexample.com%2F", "http://example.com/")
+	b := get(t, srv.URL+"/spec")
+	var v struct {
+		Routes []struct {
+			Path    string   `json:"path"`
+			Methods []string `json:"methods"`
+			Queries []string `json:"queries"`
+		} `json:"routes"`
+	}
+	require.Nil(t, json.Unmarshal(b, &v))
+
+	routes := make(map[string][]string)
+	for _, r := range v.Routes {
+		routes[r.Path] = r.Methods
+	}
+	require.Equal(t, []string{"GET", "HEAD"}, routes["/get"])
+	require.Equal(t, []string{"POST"}, routes["/post"])
+	require.Equal(t, []string{"*"}, routes[`/status/{code:[\d]+}`])
+	require.Contains(t, routes, "/spec")
 }
 
-func TestStatus_assertValidCodes(t *testing.T) {
+func TestOptions(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
 
-	methods := []string{
-		http.MethodGet,
-		http.MethodPost,
-		http.MethodPut,
-		http.MethodDelete,
-		http.MethodPatch,
-		http.MethodOptions,
-		http.MethodTrace,
+	for path, allow := range map[string]string{
+		"/get":        "GET, HEAD, OPTIONS",
+		"/post":       "POST, OPTIONS",
+		"/bytes/10":   "GET, HEAD, OPTIONS",
+		"/no-such":    "",
+		"/status/200": "", // handles OPTIONS itself
+	} {
+		resp, err := noFollow(http.MethodOptions, noRedirectClient(), srv.URL+path)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, allow, resp.Header.Get("Allow"), path)
+		switch {
+		case allow != "":
+			require.Equal(t, http.StatusOK, resp.StatusCode, path)
+		case path == "/no-such":
+			require.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+		}
 	}
 
-	codes := []int{200, 201, 202, 203, 204, 205, 206, 207, 208, 226,
-		300, 301, 302, 303, 304, 305, 307, 308, 400, 401, 402, 403, 404, 405, 406,
-		407, 408, 409, 410, 411, 412, 413, 414, 415, 416, 417, 418, 422, 423, 424,
-		426, 428, 429, 431, 451, 500, 501, 502, 503, 504, 505, 506, 507, 508, 510, 511}
+	// other methods on unknown paths are still not found, not disallowed
+	resp, err := http.Get(srv.URL + "/no-such")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
 
-	for _, method := range methods {
-		for _, code := range codes {
-			u := fmt.Sprintf("%s/status/%d", srv.URL, code)
-			resp, err := noFollow(method, noRedirectClient(), u)
-			require.NoErrorf(t, err, "%s %s", method, u)
-			require.Equalf(t, code, resp.StatusCode, "invalid status from %s %s", method, u)
-		}
+func TestMethodNotAllowed(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for _, c := range []struct {
+		method, path string
+		status       int
+		allow        string
+	}{
+		{"GET", "/post", http.StatusMethodNotAllowed, "POST, OPTIONS"},
+		{"POST", "/get", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{"PUT", "/ip", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{"GET", "/no-such", http.StatusNotFound, ""},
+		{"POST", "/no-such", http.StatusNotFound, ""},
+		{"GET", "/get", http.StatusOK, ""},
+	} {
+		req, _ := http.NewRequest(c.method, srv.URL+c.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, c.status, resp.StatusCode, c.method+" "+c.path)
+		require.Equal(t, c.allow, resp.Header.Get("Allow"), c.method+" "+c.path)
 	}
 }
 
-func TestStatus_invalidCodeWorks(t *testing.T) {
+func TestHeaders(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
 
-	code := 777
-	u := fmt.Sprintf("%s/status/%d", srv.URL, code)
-	resp, err := noFollowGet(noRedirectClient(), u)
-	require.Nil(t, err, u)
-	require.Equal(t, code, resp.StatusCode, u)
+	b := get(t, srv.URL+"/headers")
+	v := struct {
+		Headers map[string]string `json:"headers"`
+	}{}
+	require.Nil(t, json.Unmarshal(b, &v))
+	require.NotEmpty(t, v.Headers["User-Agent"]) // provided by default Go HTTP client
 }
 
-func TestStatus_3xxLocationHeader(t *testing.T) {
+func TestMethod(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
 
-	codes := []int{301, 302, 303, 305, 307}
+	type resp struct {
+		Method          string `json:"method"`
+		EffectiveMethod string `json:"effective_method"`
+		OverrideSource  string `json:"override_source"`
+	}
 
-	for _, code := range codes {
-		u := fmt.Sprintf("%s/status/%d", srv.URL, code)
-		resp, err := noFollowGet(noRedirectClient(), u)
-		require.Nil(t, err, u)
-		require.NotEmpty(t, resp.Header.Get("Location"), "code=%d", code)
+	cases := []struct {
+		query   string
+		headers map[string]string
+		want    resp
+	}{
+		{"", nil, resp{"POST", "POST", ""}},
+		{"?_method=delete", nil, resp{"POST", "DELETE", "_method"}},
+		{"?_method=delete", map[string]string{"X-HTTP-Method": "PATCH"}, resp{"POST", "PATCH", "X-HTTP-Method"}},
+		{"", map[string]string{"X-HTTP-Method": "PATCH", "X-HTTP-Method-Override": "put"}, resp{"POST", "PUT", "X-HTTP-Method-Override"}},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("POST", srv.URL+"/method"+c.query, nil)
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		r, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		defer r.Body.Close()
+		var v resp
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&v))
+		require.Equal(t, c.want, v)
 	}
 }
 
-func TestBytes_size(t *testing.T) {
+func TestProxyEcho_absoluteForm(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
 
-	sizes := []int{
-		0, // empty
-		1, // 1 byte
-		httpbin.BinaryChunkSize - 1, // off by one case
-		httpbin.BinaryChunkSize,     // off by one case
-		httpbin.BinaryChunkSize + 1, // off by one case
-		1 * 1024 * 1024,             // 1 MB
-		100 * 1024 * 1024,           // 100 MB
+	u, _ := url.Parse(srv.URL)
+	conn, err := net.Dial("tcp", u.Host)
+	require.Nil(t, err)
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "GET http://example.com/proxy-echo?a=b HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var v struct {
+		RequestURI   string `json:"request_uri"`
+		Host         string `json:"host"`
+		Path         string `json:"path"`
+		AbsoluteForm bool   `json:"absolute_form"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, "http://example.com/proxy-echo?a=b", v.RequestURI)
+	require.Equal(t, "example.com", v.Host)
+	require.Equal(t, "/proxy-echo", v.Path)
+	require.True(t, v.AbsoluteForm)
+}
+
+func TestScheme(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for hdr, want := range map[string]string{
+		"": `{"scheme": "http", "tls": "http", "consistent": true}`,
+		// a proxy terminating TLS
+		"X-Forwarded-Proto: https": `{"scheme": "https", "tls": "http", "x_forwarded_proto": "https",
+			"consistent": true}`,
+		"X-Forwarded-Proto: https\nForwarded: for=192.0.2.60;proto=https": `{"scheme": "https", "tls": "http",
+			"x_forwarded_proto": "https", "forwarded": "https", "consistent": true}`,
+		"X-Forwarded-Proto: https\nForwarded: proto=http": `{"scheme": "http", "tls": "http",
+			"x_forwarded_proto": "https", "forwarded": "http", "consistent": false}`,
+		"Forwarded: for=192.0.2.60;proto=http": `{"scheme": "http", "tls": "http", "forwarded": "http",
+			"consistent": true}`,
+		"X-Forwarded-Proto: javascript": `{"scheme": "http", "tls": "http", "x_forwarded_proto": "javascript",
+			"consistent": false}`,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/scheme", nil)
+		for _, line := range strings.Split(hdr, "\n") {
+			if kv := strings.SplitN(line, ": ", 2); len(kv) == 2 {
+				req.Header.Set(kv[0], kv[1])
+			}
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Nil(t, err)
+		require.JSONEq(t, want, string(b), hdr)
+	}
+}
+
+func TestRequestLine(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	for _, line := range []string{
+		"GET /request-line?a=%2F&b HTTP/1.1",
+		"PATCH http://example.com/request-line HTTP/1.0",
+	} {
+		conn, err := net.Dial("tcp", u.Host)
+		require.Nil(t, err)
+		fmt.Fprintf(conn, "%s\r\nHost: example.com\r\nConnection: close\r\n\r\n", line)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.Nil(t, err)
+
+		var v struct {
+			Method      string `json:"method"`
+			RequestURI  string `json:"request_uri"`
+			Proto       string `json:"proto"`
+			RequestLine string `json:"request_line"`
+		}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+		resp.Body.Close()
+		conn.Close()
+		require.Equal(t, line, v.RequestLine)
+		require.Equal(t, strings.Fields(line), []string{v.Method, v.RequestURI, v.Proto})
+	}
+}
+
+func TestTiming(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		io.WriteString(pw, "hello ")
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(pw, "world")
+		pw.Close()
+	}()
+	before := time.Now()
+	resp, err := http.Post(srv.URL+"/timing", "text/plain", pr)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	var v struct {
+		ReceivedAt  time.Time `json:"received_at"`
+		RespondedAt time.Time `json:"responded_at"`
+		BodyBytes   int64     `json:"body_bytes"`
+		BodyReadMs  float64   `json:"body_read_ms"`
+		HandlerMs   float64   `json:"handler_ms"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.EqualValues(t, 11, v.BodyBytes)
+	require.True(t, !v.ReceivedAt.Before(before.Add(-time.Second)))
+	require.True(t, v.RespondedAt.After(v.ReceivedAt))
+	require.True(t, v.BodyReadMs >= 50, "%v", v.BodyReadMs)
+	require.True(t, v.HandlerMs >= v.BodyReadMs)
+}
+
+func TestResolve(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type result struct {
+		Target  string `json:"target"`
+		Decoded string `json:"decoded"`
+		Cleaned string `json:"cleaned"`
+		Changed bool   `json:"changed"`
+	}
+	for target, want := range map[string]result{
+		"/a/b":                {"/a/b", "/a/b", "/a/b", false},
+		"/a/../../etc/passwd": {"/a/../../etc/passwd", "/a/../../etc/passwd", "/etc/passwd", true},
+		"/a/%2e%2e/b":         {"/a/%2e%2e/b", "/a/../b", "/b", true},
+		"/a%20b":              {"/a%20b", "/a b", "/a b", false}, // only decoded
+		"/a//b/":              {"/a//b/", "/a//b/", "/a/b", true},
+	} {
+		var v result
+		require.Nil(t, json.Unmarshal(get(t, srv.URL+"/resolve?target="+url.QueryEscape(target)), &v))
+		require.Equal(t, want, v, target)
+	}
+}
+
+func TestGet(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	b := get(t, srv.URL+"/get?k1=v1&k1=v2&k3=v3")
+	v := struct {
+		URL     string                 `json:"url"`
+		Args    map[string]interface{} `json:"args"`
+		Headers map[string]string      `json:"headers"`
+		Origin  string                 `json:"origin"`
+	}{}
+	require.Nil(t, json.Unmarshal(b, &v))
+	require.Equal(t, srv.URL+"/get?k1=v1&k1=v2&k3=v3", v.URL)
+	require.NotEmpty(t, v.Args, "args empty")
+	require.EqualValues(t, map[string]interface{}{
+		"k1": []interface{}{"v1", "v2"},
+		"k3": "v3",
+	}, v.Args)
+	require.NotEmpty(t, v.Headers)
+	require.NotEmpty(t, v.Origin)
+}
+
+func TestGet_url(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/get?b=%2f+x&a", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	var v struct {
+		URL string `json:"url"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, "https://"+strings.TrimPrefix(srv.URL, "http://")+"/get?b=%2f+x&a", v.URL)
+}
+
+func TestGet_showHeaders(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/get?show_headers=user-agent,X-FOO,x-missing", nil)
+	req.Header.Set("X-Foo", "bar")
+	req.Header.Set("X-Other", "baz")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	v := struct {
+		Headers map[string]string `json:"headers"`
+	}{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Len(t, v.Headers, 2)
+	require.Equal(t, "bar", v.Headers["X-Foo"])
+	require.NotEmpty(t, v.Headers["User-Agent"])
+}
+
+func TestGet_proxyInfo(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type result struct {
+		Proxied *bool    `json:"proxied"`
+		Via     []string `json:"via"`
+	}
+	var v result
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+"/get"), &v))
+	require.Nil(t, v.Proxied)
+
+	v = result{}
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+"/get?proxy_info=1"), &v))
+	require.False(t, *v.Proxied)
+
+	req, _ := http.NewRequest("GET", srv.URL+"/get?proxy_info=1", nil)
+	req.Header.Add("Via", "1.1 edge, 1.0 fred")
+	req.Header.Add("Via", "1.1 origin-shield")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	v = result{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.True(t, *v.Proxied)
+	require.Equal(t, []string{"1.1 edge", "1.0 fred", "1.1 origin-shield"}, v.Via)
+}
+
+func TestGet_shuffle(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	var plain, shuffled map[string]interface{}
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+"/get?a=1&b=2&c=3"), &plain))
+
+	b := get(t, srv.URL+"/get?a=1&b=2&c=3&shuffle=1&seed=1")
+	require.Equal(t, b, get(t, srv.URL+"/get?a=1&b=2&c=3&shuffle=1&seed=1"))
+	require.Nil(t, json.Unmarshal(b, &shuffled))
+	delete(plain["args"].(map[string]interface{}), "shuffle")
+	delete(shuffled["args"].(map[string]interface{}), "shuffle")
+	delete(shuffled["args"].(map[string]interface{}), "seed")
+	require.Equal(t, plain["args"], shuffled["args"])
+
+	orders := map[string]bool{}
+	for seed := 1; seed <= 10; seed++ {
+		orders[string(get(t, fmt.Sprintf("%s/get?a=1&b=2&c=3&shuffle=1&seed=%d", srv.URL, seed)))] = true
+	}
+	require.True(t, len(orders) > 1, "key order does not depend on the seed")
+}
+
+func TestPost(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	data := `{[{"k1": "v1"}]}`
+
+	b := post(t, srv.URL+"/post?k1=v1&k1=v2&k3=v3", []byte(data))
+	v := struct {
+		URL     string                 `json:"url"`
+		Args    map[string]interface{} `json:"args"`
+		Headers map[string]string      `json:"headers"`
+		Origin  string                 `json:"origin"`
+		Data    string                 `json:"data"`
+		JSON    interface{}            `json:"json"`
+	}{}
+	require.Nil(t, json.Unmarshal(b, &v))
+	require.EqualValues(t, map[string]interface{}{
+		"k1": []interface{}{"v1", "v2"},
+		"k3": "v3",
+	}, v.Args)
+
+	require.EqualValues(t, data, v.Data)
+	require.Equal(t, srv.URL+"/post?k1=v1&k1=v2&k3=v3", v.URL)
+	require.NotEmpty(t, v.Headers)
+	require.NotEmpty(t, v.Origin)
+}
+
+func TestPutPatchDelete(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		path := "/" + strings.ToLower(method)
+		req, _ := http.NewRequest(method, srv.URL+path+"?k=v", strings.NewReader(`{"a":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, method)
+
+		var v struct {
+			Args map[string]interface{} `json:"args"`
+			Data string                 `json:"data"`
+			JSON interface{}            `json:"json"`
+		}
+		require.Nil(t, json.Unmarshal(b, &v))
+		require.Equal(t, map[string]interface{}{"k": "v"}, v.Args)
+		require.Equal(t, `{"a":1}`, v.Data)
+		require.Equal(t, map[string]interface{}{"a": float64(1)}, v.JSON)
+
+		resp, err = http.Get(srv.URL + path)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, method)
+		require.Equal(t, method+", OPTIONS", resp.Header.Get("Allow"), method)
+	}
+
+	// no body, even though it claims to be JSON
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/delete", nil)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var v map[string]interface{}
+	require.Nil(t, json.Unmarshal(b, &v))
+	require.Equal(t, "", v["data"])
+	require.Nil(t, v["json"])
+}
+
+func TestAnything(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type anything struct {
+		Method string                 `json:"method"`
+		URL    string                 `json:"url"`
+		Args   map[string]interface{} `json:"args"`
+		Data   string                 `json:"data"`
+		Files  map[string]string      `json:"files"`
+		Form   map[string]interface{} `json:"form"`
+		JSON   interface{}            `json:"json"`
+	}
+	do := func(method, path, contentType string, body io.Reader) anything {
+		req, _ := http.NewRequest(method, srv.URL+path, body)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, method)
+		var v anything
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+		return v
+	}
+
+	for _, method := range []string{"GET", "PUT", "DELETE", "OPTIONS", "TRACE", "PROPFIND"} {
+		v := do(method, "/anything/a/b?x=1", "", nil)
+		require.Equal(t, method, v.Method)
+		require.Equal(t, srv.URL+"/anything/a/b?x=1", v.URL)
+		require.Equal(t, map[string]interface{}{"x": "1"}, v.Args)
+	}
+
+	v := do("POST", "/anything", "application/json", strings.NewReader(`{"a":[1]}`))
+	require.Equal(t, `{"a":[1]}`, v.Data)
+	require.Equal(t, map[string]interface{}{"a": []interface{}{float64(1)}}, v.JSON)
+	require.Empty(t, v.Form)
+
+	v = do("PATCH", "/anything", "application/x-www-form-urlencoded", strings.NewReader("k=1&k=2&j=3"))
+	require.Equal(t, "", v.Data)
+	require.Equal(t, map[string]interface{}{"k": []interface{}{"1", "2"}, "j": "3"}, v.Form)
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("name", "value")
+	fw, _ := mw.CreateFormFile("text", "a.txt")
+	io.WriteString(fw, "hello")
+	fw, _ = mw.CreateFormFile("bin", "b.bin")
+	fw.Write([]byte{0xff, 0xfe})
+	mw.Close()
+	v = do("POST", "/anything", mw.FormDataContentType(), &buf)
+	require.Equal(t, map[string]interface{}{"name": "value"}, v.Form)
+	require.Equal(t, map[string]string{
+		"text": "hello",
+		"bin":  "data:application/octet-stream;base64,//4=",
+	}, v.Files)
+}
+
+func TestPost_form(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type postForm struct {
+		Data  string                 `json:"data"`
+		Form  map[string]interface{} `json:"form"`
+		Files map[string]string      `json:"files"`
+	}
+	resp, err := http.Post(srv.URL+"/post", "application/x-www-form-urlencoded", strings.NewReader("k1=a&k1=b&k2=c"))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	var v postForm
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, postForm{
+		Form:  map[string]interface{}{"k1": []interface{}{"a", "b"}, "k2": "c"},
+		Files: map[string]string{},
+	}, v)
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("k", "v")
+	fw, _ := mw.CreateFormFile("upload", "a.txt")
+	io.WriteString(fw, "file contents")
+	mw.Close()
+	resp, err = http.Post(srv.URL+"/post?merge=1&k=q", mw.FormDataContentType(), &buf)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	var w struct {
+		postForm
+		Params map[string]interface{} `json:"params"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&w))
+	require.Equal(t, postForm{
+		Form:  map[string]interface{}{"k": "v"},
+		Files: map[string]string{"upload": "file contents"},
+	}, w.postForm)
+	require.Equal(t, map[string]interface{}{"merge": "1", "k": "v"}, w.Params)
+
+	resp, err = http.Post(srv.URL+"/post", "multipart/form-data; boundary=x", strings.NewReader("garbage"))
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestPost_merge(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/post?merge=1&k1=q1&k1=q2&k2=q3", "application/x-www-form-urlencoded",
+		bytes.NewBufferString("k1=b1&k3=b2&k3=b3"))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var v struct {
+		Params map[string]interface{} `json:"params"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.EqualValues(t, map[string]interface{}{
+		"merge": "1",
+		"k1":    "b1",
+		"k2":    "q3",
+		"k3":    []interface{}{"b2", "b3"},
+	}, v.Params)
+}
+
+func TestPost_gzipBody(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("hello"))
+	zw.Close()
+
+	req, _ := http.NewRequest("POST", srv.URL+"/post", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var v struct {
+		Data string `json:"data"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, "hello", v.Data)
+}
+
+func TestPost_wrongContentEncoding(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("POST", srv.URL+"/post", bytes.NewBufferString("not gzipped"))
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	var v struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Contains(t, v.Error.Message, `Content-Encoding "gzip"`)
+}
+
+func TestPostStream(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/post/stream", "application/json", strings.NewReader(`[{"a": 1}, "two", 3]`))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, `{"index":0,"record":{"a":1}}
+{"index":1,"record":"two"}
+{"index":2,"record":3}
+`, string(b))
+
+	resp, err = http.Post(srv.URL+"/post/stream", "application/json", strings.NewReader(`{"a": 1}`))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/post/stream", "application/json", strings.NewReader(`[1, }`))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	b, err = ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[1], `"error"`)
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	body := []byte(`{"event":"push"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	sum := mac.Sum(nil)
+
+	for _, tc := range []struct {
+		query, header, sig string
+		valid              bool
+	}{
+		{"", "X-Signature", hex.EncodeToString(sum), true},
+		{"&encoding=base64&header=X-Hub-Signature-256", "X-Hub-Signature-256", base64.StdEncoding.EncodeToString(sum), true},
+		{"", "X-Signature", "sha256=" + strings.ToUpper(hex.EncodeToString(sum)), true},
+		{"", "X-Signature", "deadbeef", false},
+		{"", "X-Signature", "not hex", false},
+	} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/verify-hmac?secret=s3cret"+tc.query, bytes.NewReader(body))
+		require.Nil(t, err)
+		req.Header.Set(tc.header, tc.sig)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		var v struct {
+			Valid    bool   `json:"valid"`
+			Expected string `json:"expected"`
+		}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+		resp.Body.Close()
+		require.Equal(t, tc.valid, v.Valid, tc.sig)
+		if tc.valid {
+			require.True(t, strings.EqualFold(strings.TrimPrefix(tc.sig, "sha256="), v.Expected), v.Expected)
+		}
+	}
+}
+
+func TestVerifyLength(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type result struct {
+		Declared int64 `json:"declared"`
+		Received int64 `json:"received"`
+		Match    bool  `json:"match"`
+	}
+	var v result
+	require.Nil(t, json.Unmarshal(post(t, srv.URL+"/verify-length", []byte("hello")), &v))
+	require.Equal(t, result{5, 5, true}, v)
+
+	// declare more than is sent
+	c, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	_, err = io.WriteString(c, "POST /verify-length HTTP/1.1\r\nHost: x\r\nContent-Length: 10\r\n\r\nhey")
+	require.Nil(t, err)
+	require.Nil(t, c.(*net.TCPConn).CloseWrite())
+	resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	v = result{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, result{10, 3, false}, v)
+}
+
+func TestUploadTrailer(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type result struct {
+		Valid    bool   `json:"valid"`
+		Expected string `json:"expected"`
+	}
+	sum := sha256.Sum256([]byte("hello"))
+	for _, c := range []struct {
+		digest string
+		valid  bool
+	}{
+		{hex.EncodeToString(sum[:]), true},
+		{base64.StdEncoding.EncodeToString(sum[:]), true},
+		{"bogus", false},
+	} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/upload-trailer", ioutil.NopCloser(strings.NewReader("hello")))
+		require.Nil(t, err)
+		req.ContentLength = -1
+		req.Trailer = http.Header{"X-Checksum-Sha256": {c.digest}}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var v result
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+		resp.Body.Close()
+		require.Equal(t, c.valid, v.Valid, c.digest)
+		require.Equal(t, hex.EncodeToString(sum[:]), v.Expected)
+	}
+
+	resp, err := http.Post(srv.URL+"/upload-trailer", "text/plain", strings.NewReader("hello"))
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestStreamUpload(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	var body bytes.Buffer
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(&body, "{\"n\": %d}\n", i)
+	}
+	body.WriteString("not json\n")
+
+	b := post(t, srv.URL+"/stream-upload?every=10", body.Bytes())
+	type ack struct {
+		Lines   int  `json:"lines"`
+		Invalid int  `json:"invalid"`
+		Done    bool `json:"done"`
+	}
+	var acks []ack
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var v ack
+		require.Nil(t, dec.Decode(&v))
+		acks = append(acks, v)
+	}
+	require.Equal(t, []ack{
+		{Lines: 10},
+		{Lines: 20},
+		{Lines: 26, Invalid: 1, Done: true},
+	}, acks)
+}
+
+func TestRedirect(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	assertLocationHeader(t, srv.URL+"/redirect/0", "/get")
+	assertLocationHeader(t, srv.URL+"/redirect/1", "/get")
+	assertLocationHeader(t, srv.URL+"/redirect/2", "/redirect/1")
+	assertLocationHeader(t, srv.URL+"/redirect/100", "/redirect/99")
+}
+
+func TestAbsoluteRedirect(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	assertLocationHeader(t, srv.URL+"/absolute-redirect/0", srv.URL+"/get")
+	assertLocationHeader(t, srv.URL+"/absolute-redirect/1", srv.URL+"/get")
+	assertLocationHeader(t, srv.URL+"/absolute-redirect/2", srv.URL+"/absolute-redirect/1")
+	assertLocationHeader(t, srv.URL+"/absolute-redirect/100", srv.URL+"/absolute-redirect/99")
+}
+
+func TestRedirectTo(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	assertLocationHeader(t, srv.URL+"/redirect-to?url=ip", "ip")
+	assertLocationHeader(t, srv.URL+"/redirect-to?url=/ip", "/ip")
+	assertLocationHeader(t, srv.URL+"/redirect-to?url=http%3A%2F%2Fexample.com%2F", "http://example.com/")
+}
+
+func TestRedirectPreserve(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for query, loc := range map[string]string{
+		"":                                     "/get",
+		"to=/anything&a=1":                     "/anything",
+		"to=/get&keep_query=1&a=1&b=x%20y&a=2": "/get?a=1&b=x%20y&a=2",
+		"a=1&keep_query=1&to=%2Fget%3Fz%3D0":   "/get?z=0&a=1",
+		"to=/get%23frag&keep_query=1&a=1":      "/get?a=1#frag",
+		"to=/get&keep_query=1":                 "/get",
+	} {
+		resp, err := noFollow(http.MethodGet, noRedirectClient(), srv.URL+"/redirect-preserve?"+query)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode, query)
+		require.Equal(t, loc, resp.Header.Get("Location"), query)
+	}
+}
+
+func TestRedirectPost(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	cl := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := cl.Post(srv.URL+"/redirect-post?status=301", "text/plain", nil)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	require.Equal(t, "/get", resp.Header.Get("Location"))
+	var v struct {
+		Method string `json:"method"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, http.MethodPost, v.Method)
+
+	// the client downgrades to GET on 301 but keeps POST on 307
+	resp, err = http.Post(srv.URL+"/redirect-post?status=301", "text/plain", nil)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/redirect-post?status=307&url=/post", "text/plain", strings.NewReader("x"))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "/post", resp.Request.URL.Path)
+	require.Equal(t, http.MethodPost, resp.Request.Method)
+}
+
+func TestStatus_assertValidCodes(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	methods := []string{
+		http.MethodGet,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodDelete,
+		http.MethodPatch,
+		http.MethodOptions,
+		http.MethodTrace,
+	}
+
+	codes := []int{200, 201, 202, 203, 204, 205, 206, 207, 208, 226,
+		300, 301, 302, 303, 304, 305, 307, 308, 400, 401, 402, 403, 404, 405, 406,
+		407, 408, 409, 410, 411, 412, 413, 414, 415, 416, 417, 418, 422, 423, 424,
+		426, 428, 429, 431, 451, 500, 501, 502, 503, 504, 505, 506, 507, 508, 510, 511}
+
+	for _, method := range methods {
+		for _, code := range codes {
+			u := fmt.Sprintf("%s/status/%d", srv.URL, code)
+			resp, err := noFollow(method, noRedirectClient(), u)
+			require.NoErrorf(t, err, "%s %s", method, u)
+			require.Equalf(t, code, resp.StatusCode, "invalid status from %s %s", method, u)
+		}
+	}
+}
+
+func TestStatus_invalidCodeWorks(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	code := 777
+	u := fmt.Sprintf("%s/status/%d", srv.URL, code)
+	resp, err := noFollowGet(noRedirectClient(), u)
+	require.Nil(t, err, u)
+	require.Equal(t, code, resp.StatusCode, u)
+}
+
+func TestStatus_3xxLocationHeader(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	codes := []int{301, 302, 303, 305, 307}
+
+	for _, code := range codes {
+		u := fmt.Sprintf("%s/status/%d", srv.URL, code)
+		resp, err := noFollowGet(noRedirectClient(), u)
+		require.Nil(t, err, u)
+		require.NotEmpty(t, resp.Header.Get("Location"), "code=%d", code)
+	}
+}
+
+func TestStatus_head(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for code, header := range map[int]string{418: "x-more-info", 301: "Location"} {
+		u := fmt.Sprintf("%s/status/%d", srv.URL, code)
+		resp, err := noFollow(http.MethodHead, noRedirectClient(), u)
+		require.Nil(t, err, u)
+		defer resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode, u)
+		require.NotEmpty(t, resp.Header.Get(header), u)
+		b, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		require.Empty(t, b, u)
+	}
+}
+
+func TestStatus_body(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/status/422?body=%7B%22error%22%3A%22bad%20input%22%7D&content_type=application/problem%2Bjson")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, 422, resp.StatusCode)
+	require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, `{"error":"bad input"}`, string(b))
+
+	resp, err = http.Get(srv.URL + "/status/503?body=down+for+maintenance")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	b, err = ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, "down for maintenance", string(b))
+
+	// replaces a default body
+	resp, err = http.Get(srv.URL + "/status/418?body=")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	b, err = ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Empty(t, b)
+}
+
+func TestStatus_bodyContentType(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for ct, status := range map[string]int{
+		"":                          http.StatusOK,
+		"application/json":          http.StatusOK,
+		"text/plain; charset=utf-8": http.StatusOK,
+		"text/html":                 http.StatusBadRequest,
+		"TEXT/HTML; charset=utf-8":  http.StatusBadRequest,
+		"application/xhtml+xml":     http.StatusBadRequest,
+		"image/svg+xml":             http.StatusBadRequest,
+		"text/javascript":           http.StatusBadRequest,
+		"application/javascript":    http.StatusBadRequest,
+		"application/ecmascript":    http.StatusBadRequest,
+		"text/xml":                  http.StatusBadRequest,
+		"application/xml":           http.StatusBadRequest,
+		"text/xsl":                  http.StatusBadRequest,
+		"application/atom+xml":      http.StatusBadRequest,
+		"text/html;":                http.StatusBadRequest, // malformed
+		"application/problem+json":  http.StatusOK,
+		"text/csv":                  http.StatusOK,
+		"APPLICATION/JSON":          http.StatusOK,
+	} {
+		u := srv.URL + "/status/200?body=%3Cscript%3Ealert(1)%3C%2Fscript%3E"
+		if ct != "" {
+			u += "&content_type=" + url.QueryEscape(ct)
+		}
+		resp, err := http.Get(u)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, status, resp.StatusCode, ct)
+		if status == http.StatusOK {
+			require.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"), ct)
+		}
+	}
+}
+
+func TestNoContent(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/no-content")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("Content-Type"))
+	require.Empty(t, resp.Header.Get("Content-Length"))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Empty(t, b)
+}
+
+func TestReset(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for _, stage := range []string{"before", "after"} {
+		resp, err := http.Get(srv.URL + "/reset?stage=" + stage)
+		if err == nil {
+			_, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		require.NotNil(t, err, stage)
+	}
+}
+
+func TestHopByHop(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type result struct {
+		Present  bool              `json:"present"`
+		HopByHop map[string]string `json:"hop_by_hop"`
+		Headers  map[string]string `json:"headers"`
+	}
+	var v result
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+"/hop-by-hop"), &v))
+	require.False(t, v.Present)
+	require.Empty(t, v.HopByHop)
+	require.Nil(t, v.Headers)
+
+	c, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	_, err = io.WriteString(c, "POST /hop-by-hop?strip=1 HTTP/1.1\r\nHost: x\r\n"+
+		"Connection: keep-alive, X-Private\r\nKeep-Alive: timeout=5\r\nTE: trailers\r\n"+
+		"X-Private: 1\r\nX-Public: 2\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n")
+	require.Nil(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	v = result{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.True(t, v.Present)
+	require.Equal(t, "timeout=5", v.HopByHop["Keep-Alive"])
+	require.Equal(t, "trailers", v.HopByHop["Te"])
+	require.Equal(t, "1", v.HopByHop["X-Private"])
+	require.Equal(t, "chunked", v.HopByHop["Transfer-Encoding"])
+	require.Contains(t, v.HopByHop, "Connection")
+	require.Equal(t, "2", v.Headers["X-Public"])
+	require.NotContains(t, v.Headers, "X-Private")
+}
+
+func TestRedactedHeaders(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/headers/redacted?redact=x-api-key,,X-Missing", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Proxy-Authorization", "secret")
+	req.Header.Set("Cookie", "a=1; b=2")
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("X-Other", "visible")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	var v struct {
+		Headers map[string]string `json:"headers"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, "Bearer ***", v.Headers["Authorization"])
+	require.Equal(t, "***", v.Headers["Proxy-Authorization"])
+	require.Equal(t, "a=***; b=***", v.Headers["Cookie"])
+	require.Equal(t, "***", v.Headers["X-Api-Key"])
+	require.Equal(t, "visible", v.Headers["X-Other"])
+	_, ok := v.Headers["X-Missing"]
+	require.False(t, ok)
+}
+
+func TestOrderedHeaders(t *testing.T) {
+	srv := httptest.NewUnstartedServer(httpbin.GetMux())
+	srv.Listener = httpbin.Listener(srv.Listener)
+	srv.Config.ConnContext = httpbin.ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	c, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	for i := 0; i < 2; i++ { // same answer on a reused connection
+		_, err = io.WriteString(c, "GET /headers/ordered HTTP/1.1\r\n"+
+			"x-zeta: 1\r\nHost: example\r\nX-Alpha: 2\r\nx-zeta: 3\r\n\r\n")
+		require.Nil(t, err)
+		resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+		require.Nil(t, err)
+		var v struct {
+			Headers [][]string `json:"headers"`
+		}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+		resp.Body.Close()
+		require.Equal(t, [][]string{{"x-zeta", "1"}, {"Host", "example"}, {"X-Alpha", "2"}, {"x-zeta", "3"}}, v.Headers)
+	}
+}
+
+func TestOrderedHeaders_notAvailable(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/headers/ordered")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+}
+
+func TestDupHeaders(t *testing.T) {
+	srv := httptest.NewUnstartedServer(httpbin.GetMux())
+	srv.Listener = httpbin.Listener(srv.Listener)
+	srv.Config.ConnContext = httpbin.ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	c, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	_, err = io.WriteString(c, "POST /dup-headers?names=content-length,host,transfer-encoding,x-a HTTP/1.1\r\n"+
+		"Host: example\r\nContent-Length: 2\r\nX-A: 1\r\ncontent-length: 2\r\nX-A: 2\r\n\r\nok")
+	require.Nil(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	require.Nil(t, err)
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Nil(t, err)
+	require.JSONEq(t, `{"headers": {
+		"Content-Length": {"count": 1, "values": ["2"], "raw_count": 2, "raw_values": ["2", "2"]},
+		"Host": {"count": 1, "values": ["example"], "raw_count": 1, "raw_values": ["example"]},
+		"Transfer-Encoding": {"count": 0, "values": [], "raw_count": 0},
+		"X-A": {"count": 2, "values": ["1", "2"], "raw_count": 2, "raw_values": ["1", "2"]}}}`, string(b))
+}
+
+func TestDupHeaders_notRecorded(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	var v struct {
+		Headers map[string]struct {
+			Count    int  `json:"count"`
+			RawCount *int `json:"raw_count"`
+		} `json:"headers"`
+		Note string `json:"note"`
+	}
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+"/dup-headers"), &v))
+	require.Len(t, v.Headers, 3)
+	require.Equal(t, 1, v.Headers["Host"].Count)
+	require.Nil(t, v.Headers["Host"].RawCount)
+	require.NotEmpty(t, v.Note)
+}
+
+func TestDupHeaders_h2c(t *testing.T) {
+	srv := httptest.NewUnstartedServer(h2c.NewHandler(httpbin.GetMux(), &http2.Server{}))
+	srv.Listener = httpbin.Listener(srv.Listener)
+	srv.Config.ConnContext = httpbin.ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	cl := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	// a body on the same connection looking like an HTTP/1.x request head
+	// must not be taken for the head of the HTTP/2 request
+	resp, err := cl.Post(srv.URL+"/post", "text/plain",
+		strings.NewReader("\nGET /dup-headers HTTP/2.0\r\nHost: a\r\nHost: b\r\n\r\n"))
+	require.Nil(t, err)
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	require.Nil(t, err)
+
+	resp, err = cl.Get(srv.URL + "/dup-headers")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, 2, resp.ProtoMajor)
+
+	var v struct {
+		Headers map[string]struct {
+			RawCount *int `json:"raw_count"`
+		} `json:"headers"`
+		Note string `json:"note"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Nil(t, v.Headers["Host"].RawCount)
+	require.NotEmpty(t, v.Note)
+}
+
+func TestKeepAlive(t *testing.T) {
+	srv := testServer()
+	require.JSONEq(t, `{"read_timeout": 0, "read_header_timeout": 0, "write_timeout": 0, "idle_timeout": 0}`,
+		string(get(t, srv.URL+"/keepalive")))
+	srv.Close()
+
+	srv = httptest.NewUnstartedServer(httpbin.GetMux())
+	srv.Config.ReadTimeout = 30 * time.Second
+	srv.Config.WriteTimeout = 1500 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+	require.JSONEq(t, `{"read_timeout": 30, "read_header_timeout": 30, "write_timeout": 1.5, "idle_timeout": 30}`,
+		string(get(t, srv.URL+"/keepalive")))
+}
+
+func TestConnCount(t *testing.T) {
+	srv := httptest.NewUnstartedServer(httpbin.GetMux())
+	srv.Config.ConnContext = httpbin.ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	cl := &http.Client{Transport: &http.Transport{}}
+	for i := 1; i <= 3; i++ {
+		resp, err := cl.Get(srv.URL + "/conn-count")
+		require.Nil(t, err)
+		var v struct {
+			Count int `json:"count"`
+		}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		require.Equal(t, i, v.Count)
+	}
+}
+
+func TestConnCount_notEnabled(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/conn-count")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+}
+
+func TestMagic(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for typ, ct := range map[string]string{
+		"png": "image/png",
+		"pdf": "application/pdf",
+		"zip": "application/zip",
+		"gif": "image/gif",
+	} {
+		resp, err := http.Get(srv.URL + "/magic/" + typ + "?n=100&seed=5")
+		require.Nil(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Nil(t, err)
+		require.Equal(t, ct, resp.Header.Get("Content-Type"), typ)
+		require.Len(t, b, 100, typ)
+		require.Equal(t, ct, http.DetectContentType(b), typ)
+		require.Equal(t, b, get(t, srv.URL+"/magic/"+typ+"?n=100&seed=5"), typ)
+	}
+
+	require.Len(t, get(t, srv.URL+"/magic/png?n=1"), 8)
+
+	resp, err := http.Get(srv.URL + "/magic/exe")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestBytes_saveData(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for saveData, size := range map[string]int{"": 100000, "on": httpbin.SaveDataBytesMax, "off": 100000} {
+		req, _ := http.NewRequest("GET", srv.URL+"/bytes/100000", nil)
+		req.Header.Set("Save-Data", saveData)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Nil(t, err)
+		require.Len(t, b, size, saveData)
+		require.Contains(t, resp.Header["Vary"], "Save-Data")
+	}
+}
+
+func TestBytes_size(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	sizes := []int{
+		0, // empty
+		1, // 1 byte
+		httpbin.BinaryChunkSize - 1, // off by one case
+		httpbin.BinaryChunkSize,     // off by one case
+		httpbin.BinaryChunkSize + 1, // off by one case
+		1 * 1024 * 1024,             // 1 MB
+		100 * 1024 * 1024,           // 100 MB
 	}
 	for _, size := range sizes {
 		b := get(t, srv.URL+fmt.Sprintf("/bytes/%d", size))
@@ -283,24 +1883,424 @@ func TestBytes_size(t *testing.T) {
 	}
 }
 
-func TestBytes_noSeed(t *testing.T) {
-	srv := testServer()
+func TestBytes_max(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	defer func(v int) { httpbin.BytesMax = v }(httpbin.BytesMax)
+	httpbin.BytesMax = 100
+
+	for size, want := range map[int]int{99: 99, 100: 100, 101: 100, 1000: 100} {
+		b := get(t, srv.URL+fmt.Sprintf("/bytes/%d", size))
+		require.Len(t, b, want, "%d bytes", size)
+	}
+}
+
+func TestBytes_contentLength(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for _, method := range []string{"GET", "HEAD"} {
+		req, _ := http.NewRequest(method, srv.URL+"/bytes/100000", nil)
+		req.Header.Set("Accept-Encoding", "identity")
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Nil(t, err)
+		require.EqualValues(t, 100000, resp.ContentLength, method)
+		if method == "GET" {
+			require.Len(t, b, 100000)
+		}
+	}
+}
+
+// TestBytes_memory checks that the maximum response size is streamed without
+// allocating memory in proportion to it.
+func TestBytes_memory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("streams 1 GB")
+	}
+	srv := testServer()
+	defer srv.Close()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	req, _ := http.NewRequest("GET", srv.URL+fmt.Sprintf("/bytes/%d", httpbin.BytesMax), nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.EqualValues(t, httpbin.BytesMax, resp.ContentLength)
+	n, err := io.Copy(ioutil.Discard, resp.Body)
+	require.Nil(t, err)
+	require.EqualValues(t, httpbin.BytesMax, n)
+
+	runtime.ReadMemStats(&after)
+	allocated := after.TotalAlloc - before.TotalAlloc
+	require.True(t, allocated < 16*1024*1024, "allocated %d bytes", allocated)
+}
+
+// discardResponseWriter is an http.ResponseWriter dropping what is written.
+type discardResponseWriter struct{ h http.Header }
+
+func (w discardResponseWriter) Header() http.Header         { return w.h }
+func (w discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w discardResponseWriter) WriteHeader(int)             {}
+
+func BenchmarkBytes(b *testing.B) {
+	mux := httpbin.GetMux()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/bytes/%d", 100*1024*1024), nil)
+	b.SetBytes(100 * 1024 * 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mux.ServeHTTP(discardResponseWriter{http.Header{}}, req)
+	}
+}
+
+func TestBytes_noSeed(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	u := srv.URL + "/bytes/1024"
+	b1 := get(t, u)
+	b2 := get(t, u)
+	require.NotEqual(t, b1, b2, "generated the same bytes in multiple runs")
+}
+
+func TestBytes_seed(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	u := srv.URL + "/bytes/1024?seed=1"
+	b1 := get(t, u)
+	b2 := get(t, u)
+	require.Equal(t, b1, b2, "generated different bytes for the same seed")
+}
+
+func TestBytes_seedHeader(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	withHeader := func(u, seed string) []byte {
+		req, _ := http.NewRequest("GET", u, nil)
+		req.Header.Set("X-Seed", seed)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return b
+	}
+	b := get(t, srv.URL+"/bytes/1024?seed=1")
+	require.Equal(t, b, withHeader(srv.URL+"/bytes/1024", "1"))
+	require.Equal(t, b, withHeader(srv.URL+"/bytes/1024?seed=1", "2"), "query must take precedence")
+}
+
+func TestBytes_gzip(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/bytes/1024?seed=1", nil)
+	req.Header.Set("Accept-Encoding", "gzip") // disable transparent decompression
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	zr, err := gzip.NewReader(resp.Body)
+	require.Nil(t, err)
+	b, err := ioutil.ReadAll(zr)
+	require.Nil(t, err)
+	require.Len(t, b, 1024)
+
+	req, _ = http.NewRequest("GET", srv.URL+"/bytes/1024?seed=1", nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+	plain, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, b, plain)
+}
+
+func TestBytes_entropy(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	compressed := func(entropy string) int {
+		req, _ := http.NewRequest("GET", srv.URL+"/bytes/65536?seed=1&entropy="+entropy, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return len(b)
+	}
+	require.True(t, compressed("low") < 65536/10)
+	require.True(t, compressed("high") > 65536)
+
+	low := get(t, srv.URL+"/bytes/65536?seed=1&entropy=low")
+	require.Len(t, low, 65536)
+	require.Equal(t, low, get(t, srv.URL+"/bytes/65536?seed=1&entropy=low"))
+
+	resp, err := http.Get(srv.URL + "/bytes/10?entropy=medium")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSlow(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/slow/5?per-byte-ms=10")
+	require.Nil(t, err)
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Nil(t, err)
+	require.Equal(t, "*****", string(b))
+	require.True(t, time.Since(start) >= 40*time.Millisecond)
+
+	// gives up long before all bytes are written
+	start = time.Now()
+	resp, err = http.Get(srv.URL + "/slow/1000?per-byte-ms=50&max-seconds=0.2")
+	require.Nil(t, err)
+	require.Equal(t, "1000", resp.Header.Get("Content-Length"))
+	b, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	require.True(t, len(b) > 0 && len(b) < 10, "%d", len(b))
+	require.True(t, time.Since(start) < time.Second)
+
+	resp, err = http.Get(srv.URL + "/slow/5?max-seconds=0")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestThrottle(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	start := time.Now()
+	b := get(t, srv.URL+"/throttle/3000?kbps=80") // 10 KB/s
+	elapsed := time.Since(start)
+	require.Len(t, b, 3000)
+	require.True(t, elapsed > 150*time.Millisecond, "elapsed=%v", elapsed)
+	require.True(t, elapsed < 2*time.Second, "elapsed=%v", elapsed)
+}
+
+func TestWords(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	a := get(t, srv.URL+"/words/50?seed=42")
+	require.Len(t, strings.Fields(string(a)), 50)
+	require.Equal(t, a, get(t, srv.URL+"/words/50?seed=42"))
+	require.NotEqual(t, a, get(t, srv.URL+"/words/50?seed=43"))
+
+	resp, err := http.Get(fmt.Sprintf("%s/words/%d", srv.URL, httpbin.WordsMax+1))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestStruct(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	le := get(t, srv.URL+"/struct?seed=7&count=4")
+	require.Len(t, le, 16)
+	require.Equal(t, le, get(t, srv.URL+"/struct?seed=7&count=4"))
+
+	be := get(t, srv.URL+"/struct?seed=7&count=4&endian=big")
+	for i := 0; i < 4; i++ {
+		require.Equal(t, binary.LittleEndian.Uint32(le[i*4:]), binary.BigEndian.Uint32(be[i*4:]))
+	}
+}
+
+func TestProtobuf(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/protobuf?seed=3")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/x-protobuf", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.NotEmpty(t, b)
+	require.Equal(t, byte(0x08), b[0]) // field 1, varint
+	require.Equal(t, b, get(t, srv.URL+"/protobuf?seed=3"))
+}
+
+func TestMsgpack(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/msgpack")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/msgpack", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.NotEmpty(t, b)
+	require.Equal(t, byte(0x81), b[0]) // fixmap of 1, "slideshow"
+	require.Equal(t, b, get(t, srv.URL+"/msgpack"))
+}
+
+func TestJSON(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/json")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var v struct {
+		Slideshow struct {
+			Title  string `json:"title"`
+			Slides []struct {
+				Title string   `json:"title"`
+				Items []string `json:"items"`
+			} `json:"slides"`
+		} `json:"slideshow"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, "Sample Slide Show", v.Slideshow.Title)
+	require.Len(t, v.Slideshow.Slides, 2)
+	require.Equal(t, "Overview", v.Slideshow.Slides[1].Title)
+	require.Len(t, v.Slideshow.Slides[1].Items, 3)
+}
+
+func TestFuzzJSON(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type node struct {
+		Label    string   `json:"label"`
+		Value    *float64 `json:"value"`
+		Children []node   `json:"children"`
+	}
+	type document struct {
+		ID       int64    `json:"id"`
+		Name     string   `json:"name"`
+		Score    float64  `json:"score"`
+		Active   bool     `json:"active"`
+		Tags     []string `json:"tags"`
+		Note     *string  `json:"note"`
+		Children []node   `json:"children"`
+	}
+	var checkNodes func([]node, int)
+	checkNodes = func(nodes []node, depth int) {
+		require.NotNil(t, nodes)
+		require.True(t, depth <= 5)
+		for _, n := range nodes {
+			checkNodes(n.Children, depth+1)
+		}
+	}
+
+	for seed := 0; seed < 20; seed++ {
+		u := srv.URL + "/fuzz-json?seed=" + strconv.Itoa(seed)
+		b := get(t, u)
+		require.Equal(t, b, get(t, u))
+
+		var v document
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		require.Nil(t, d.Decode(&v))
+		require.NotNil(t, v.Tags)
+		checkNodes(v.Children, 1)
+	}
+}
+
+func TestItems(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/items?page=2&per_page=20&total=50")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	link := resp.Header.Get("Link")
+	require.Contains(t, link, `/items?page=1&per_page=20&total=50>; rel="first"`)
+	require.Contains(t, link, `/items?page=1&per_page=20&total=50>; rel="prev"`)
+	require.Contains(t, link, `/items?page=3&per_page=20&total=50>; rel="next"`)
+	require.Contains(t, link, `/items?page=3&per_page=20&total=50>; rel="last"`)
+
+	var v struct {
+		Total int `json:"total"`
+		Items []struct {
+			ID int `json:"id"`
+		} `json:"items"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, 50, v.Total)
+	require.Len(t, v.Items, 20)
+	require.Equal(t, 21, v.Items[0].ID)
+}
+
+func TestItems_outOfRange(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/items?page=4&per_page=20&total=50")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestMixedEncoding(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	b := get(t, srv.URL+"/encoding/mixed?seed=5")
+	require.False(t, utf8.Valid(b))
+	require.Equal(t, b, get(t, srv.URL+"/encoding/mixed?seed=5"))
+
+	var valid int
+	for _, f := range bytes.Fields(b) {
+		if utf8.Valid(f) {
+			valid++
+		}
+	}
+	require.True(t, valid > 0)
+}
+
+func TestDelay_concurrencyLimit(t *testing.T) {
+	srv := httptest.NewServer(httpbin.GetMux(httpbin.WithDelayConcurrencyLimit(1)))
 	defer srv.Close()
 
-	u := srv.URL + "/bytes/1024"
-	b1 := get(t, u)
-	b2 := get(t, u)
-	require.NotEqual(t, b1, b2, "generated the same bytes in multiple runs")
-}
+	done := make(chan int)
+	go func() {
+		resp, err := http.Get(srv.URL + "/delay/0.5")
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	time.Sleep(100 * time.Millisecond)
 
-func TestBytes_seed(t *testing.T) {
-	srv := testServer()
-	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/delay/0.5")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get("Retry-After"))
+	require.Equal(t, http.StatusOK, <-done)
 
-	u := srv.URL + "/bytes/1024?seed=1"
-	b1 := get(t, u)
-	b2 := get(t, u)
-	require.Equal(t, b1, b2, "generated different bytes for the same seed")
+	resp, err = http.Get(srv.URL + "/delay/0")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
 func TestDelay_supportsFloat(t *testing.T) {
@@ -329,6 +2329,80 @@ func TestDelay_limited(t *testing.T) {
 	require.InEpsilon(t, e, 0.3, 0.1, "max=%v elapsed=%vs", httpbin.DelayMax, e)
 }
 
+func TestLatency(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	latency := func(query string) int {
+		resp, err := http.Get(srv.URL + "/latency?" + query)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, query)
+		ms, err := strconv.Atoi(resp.Header.Get("X-Latency-Ms"))
+		require.Nil(t, err, query)
+		return ms
+	}
+	for _, query := range []string{
+		"mean=0.05&stddev=0.01&seed=1",
+		"dist=exponential&mean=0.02&seed=2",
+		"dist=uniform&min=0.01&max=0.05&seed=3",
+	} {
+		ms := latency(query)
+		require.Equal(t, ms, latency(query), "not reproducible: %s", query)
+		require.True(t, ms >= 0 && ms <= 200, "%s: %d ms", query, ms)
+	}
+	require.Equal(t, 0, latency("mean=0&stddev=0")) // clamped
+	require.Equal(t, 0, latency("mean=0.01&stddev=1&seed=1"), "negative sample not clamped")
+
+	for _, query := range []string{"dist=pareto", "mean=-1", "mean=NaN", "stddev=Inf", "stddev=x", "dist=uniform&min=2&max=1", "seed=x"} {
+		resp, err := http.Get(srv.URL + "/latency?" + query)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func TestLatency_clamped(t *testing.T) {
+	orig := httpbin.DelayMax
+	defer func() { httpbin.DelayMax = orig }()
+	httpbin.DelayMax = 50 * time.Millisecond
+	srv := testServer()
+	defer srv.Close()
+
+	for _, query := range []string{
+		"mean=1e300&stddev=0",
+		"dist=exponential&mean=1e300&seed=1",
+		"dist=uniform&min=1e20&max=1e20",
+	} {
+		resp, err := http.Get(srv.URL + "/latency?" + query)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, query)
+		require.Equal(t, "50", resp.Header.Get("X-Latency-Ms"), query)
+	}
+}
+
+func TestRandomDelay(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	start := time.Now()
+	get(t, srv.URL+"/delay/random?min=0.1&max=0.2")
+	elapsed := time.Since(start)
+	require.True(t, elapsed >= 100*time.Millisecond, "elapsed=%v", elapsed)
+	require.True(t, elapsed < time.Second, "elapsed=%v", elapsed)
+}
+
+func TestRandomDelay_invalidRange(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/delay/random?min=0.5&max=0.1")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
 func TestStream(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -371,6 +2445,346 @@ func TestStream(t *testing.T) {
 	require.Equal(t, total, n, "some messages not received")
 }
 
+func TestStream_max(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	defer func(v time.Duration) { httpbin.StreamInterval = v }(httpbin.StreamInterval)
+	httpbin.StreamInterval = time.Millisecond
+	defer func(v int) { httpbin.StreamMax = v }(httpbin.StreamMax)
+	httpbin.StreamMax = 3
+
+	for total, want := range map[int]int{2: 2, 3: 3, 4: 3, 1000: 3} {
+		b := get(t, srv.URL+fmt.Sprintf("/stream/%d", total))
+		require.Equal(t, want, bytes.Count(b, []byte("\n")), "%d lines", total)
+	}
+}
+
+func TestStream_ramp(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	orig := httpbin.StreamInterval
+	httpbin.StreamInterval = time.Millisecond * 50
+	defer func() { httpbin.StreamInterval = orig }()
+
+	resp, err := http.Get(srv.URL + "/stream/5?ramp=3")
+	require.Nil(t, err, "request failed")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	dec := json.NewDecoder(resp.Body)
+	var gaps []float64
+	var last time.Time
+	for {
+		var m struct{}
+		err := dec.Decode(&m)
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err, "cannot decode msg")
+		if !last.IsZero() {
+			gaps = append(gaps, time.Since(last).Seconds()*1000)
+		}
+		last = time.Now()
+	}
+	require.Len(t, gaps, 4)
+	require.InDelta(t, 50, gaps[0], 25)
+	require.InDelta(t, 150, gaps[3], 25)
+}
+
+func TestStream_checksum(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	orig := httpbin.StreamInterval
+	httpbin.StreamInterval = time.Millisecond
+	defer func() { httpbin.StreamInterval = orig }()
+
+	type msg struct {
+		N    int     `json:"n"`
+		Hash *string `json:"hash"`
+	}
+	var m msg
+	require.Nil(t, json.Unmarshal(bytes.SplitN(get(t, srv.URL+"/stream/1"), []byte{'\n'}, 2)[0], &m))
+	require.Nil(t, m.Hash)
+
+	lines := bytes.SplitAfter(get(t, srv.URL+"/stream/5?checksum=1"), []byte{'\n'})
+	require.Len(t, lines, 6) // last one is empty
+	prev := sha256.Sum256(nil)
+	for i, line := range lines[:5] {
+		m = msg{}
+		require.Nil(t, json.Unmarshal(line, &m))
+		require.Equal(t, i, m.N)
+		require.NotNil(t, m.Hash)
+		require.Equal(t, hex.EncodeToString(prev[:]), *m.Hash, "line %d", i)
+		prev = sha256.Sum256(line)
+	}
+}
+
+func TestStreamTrailers(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/stream-trailers/3?interval=0.01")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.EqualValues(t, -1, resp.ContentLength)
+	require.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, 3, bytes.Count(b, []byte("\n")))
+	require.Equal(t, "3", resp.Trailer.Get("X-Stream-Count"))
+	require.Equal(t, strconv.Itoa(len(b)), resp.Trailer.Get("X-Stream-Bytes"))
+	ms, err := strconv.Atoi(resp.Trailer.Get("X-Stream-Duration-Ms"))
+	require.Nil(t, err)
+	require.True(t, ms >= 30, "duration %d ms", ms)
+}
+
+func TestTask(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/task?duration=0.1&interval=0.025")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, `{"percent":0}
+{"percent":25}
+{"percent":50}
+{"percent":75}
+{"percent":100}
+{"done":true}
+`, string(b))
+
+	resp, err = http.Get(srv.URL + "/task?interval=0")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/sse/heartbeat?interval=0.01&count=3")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, strings.Repeat(": heartbeat\n\n", 3)+"data: {\"heartbeats\": 3}\n\n", string(b))
+}
+
+func TestMultipart(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/multipart?parts=4")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	mt, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.Nil(t, err)
+	require.Equal(t, "multipart/mixed", mt)
+
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	var types []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		b, err := ioutil.ReadAll(p)
+		require.Nil(t, err)
+		require.NotEmpty(t, b)
+		types = append(types, p.Header.Get("Content-Type"))
+	}
+	require.Equal(t, []string{
+		"text/plain; charset=utf-8",
+		"application/json",
+		"text/html; charset=utf-8",
+		"text/plain; charset=utf-8",
+	}, types)
+}
+
+func TestMultipart_badParts(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for _, v := range []string{"abc", "0", "-1"} {
+		resp, err := http.Get(srv.URL + "/multipart?parts=" + v)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, v)
+	}
+}
+
+func TestGrowing(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	fetch := func(path string) (*http.Response, []byte) {
+		resp, err := client.Get(srv.URL + path)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp, b
+	}
+
+	var prev []byte
+	for i := 1; i <= 3; i++ {
+		resp, b := fetch("/growing?step=50")
+		require.Equal(t, strconv.Itoa(50*i), resp.Header.Get("Content-Length"))
+		require.Equal(t, strconv.Itoa(i), resp.Header.Get("X-Growing-Calls"))
+		require.Len(t, b, 50*i)
+		require.True(t, bytes.HasPrefix(b, prev))
+		prev = b
+	}
+
+	// other sessions grow on their own
+	_, b := fetch("/growing?step=50&session=other")
+	require.Len(t, b, 50)
+
+	resp, err := client.Post(srv.URL+"/growing/reset", "", nil)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	_, b = fetch("/growing?step=50")
+	require.Len(t, b, 50)
+}
+
+func TestResources(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type resource struct {
+		ID   string          `json:"id"`
+		Data json.RawMessage `json:"data"`
+	}
+	resp, err := http.Post(srv.URL+"/resources", "application/json", strings.NewReader(`{"name":"x"}`))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created resource
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&created))
+	require.NotEmpty(t, created.ID)
+	require.JSONEq(t, `{"name":"x"}`, string(created.Data))
+	require.Equal(t, "/resources/"+created.ID, resp.Header.Get("Location"))
+
+	var fetched resource
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+resp.Header.Get("Location")), &fetched))
+	require.Equal(t, created, fetched)
+
+	resp, err = http.Get(srv.URL + "/resources/nope")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/resources", "application/json", strings.NewReader(`{"name":`))
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestResources_ttl(t *testing.T) {
+	srv := httptest.NewServer(httpbin.GetMux(httpbin.WithResourceTTL(50 * time.Millisecond)))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/resources", "application/json", strings.NewReader(`1`))
+	require.Nil(t, err)
+	resp.Body.Close()
+	loc := resp.Header.Get("Location")
+
+	resp, err = http.Get(srv.URL + loc)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	time.Sleep(100 * time.Millisecond)
+	resp, err = http.Get(srv.URL + loc)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestResources_evict(t *testing.T) {
+	defer func(n, size int) { httpbin.ResourcesMax, httpbin.ResourcesBytesMax = n, size }(httpbin.ResourcesMax, httpbin.ResourcesBytesMax)
+	httpbin.ResourcesMax = 2
+	httpbin.ResourcesBytesMax = 10
+	srv := testServer()
+	defer srv.Close()
+
+	create := func(body string) (int, string) {
+		resp, err := http.Post(srv.URL+"/resources", "application/json", strings.NewReader(body))
+		require.Nil(t, err)
+		resp.Body.Close()
+		return resp.StatusCode, resp.Header.Get("Location")
+	}
+	status := func(loc string) int {
+		resp, err := http.Get(srv.URL + loc)
+		require.Nil(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	_, first := create(`1`)
+	_, second := create(`2`)
+	code, third := create(`3`) // evicts the first beyond ResourcesMax
+	require.Equal(t, http.StatusCreated, code)
+	require.Equal(t, http.StatusNotFound, status(first))
+	require.Equal(t, http.StatusOK, status(second))
+	require.Equal(t, http.StatusOK, status(third))
+
+	code, fourth := create(`"12345678"`) // 10 bytes, evicts both beyond ResourcesBytesMax
+	require.Equal(t, http.StatusCreated, code)
+	require.Equal(t, http.StatusNotFound, status(second))
+	require.Equal(t, http.StatusNotFound, status(third))
+	require.Equal(t, http.StatusOK, status(fourth))
+
+	code, _ = create(`"123456789"`)
+	require.Equal(t, http.StatusRequestEntityTooLarge, code)
+	require.Equal(t, http.StatusOK, status(fourth))
+}
+
+func TestPoll_trigger(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		post(t, srv.URL+"/poll/trigger", nil)
+	}()
+
+	start := time.Now()
+	b := get(t, srv.URL+"/poll?wait=5")
+	require.True(t, time.Since(start) < 2*time.Second)
+	var v struct {
+		Event  int    `json:"event"`
+		Source string `json:"source"`
+	}
+	require.Nil(t, json.Unmarshal(b, &v))
+	require.Equal(t, 1, v.Event)
+	require.Equal(t, "trigger", v.Source)
+}
+
+func TestPoll_timeout(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/poll?wait=0.1")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
 func TestCookies(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -416,15 +2830,111 @@ func TestSetCookies(t *testing.T) {
 	require.Equal(t, http.StatusFound, resp.StatusCode)
 	require.Equal(t, "/cookies", resp.Header.Get("Location"))
 
-	cs := cj.Cookies(u)
-	m := make(map[string]string, len(cs))
-	for _, v := range cs {
-		m[v.Name] = v.Value
+	cs := cj.Cookies(u)
+	m := make(map[string]string, len(cs))
+	for _, v := range cs {
+		m[v.Name] = v.Value
+	}
+	require.EqualValues(t, map[string]string{"k1": "v1", "k2": "v2"}, m)
+}
+
+func TestDeleteCookies(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.Nil(t, err)
+	cj, err := cookiejar.New(nil)
+	require.Nil(t, err)
+	cj.SetCookies(u, []*http.Cookie{
+		{Name: "k1", Value: "v1"},
+		{Name: "k2", Value: "v2"},
+		{Name: "k3", Value: "v3"},
+	})
+	cl := noRedirectClient()
+	cl.Jar = cj
+	resp, err := noFollowGet(cl, srv.URL+"/cookies/delete?k1&k2")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Equal(t, "/cookies", resp.Header.Get("Location"))
+
+	var cs []string
+	for _, c := range cj.Cookies(u) {
+		cs = append(cs, c.String())
+	}
+	if runtime.Version() >= "go1.8" {
+		require.NotContains(t, cs, "k1=")
+		require.NotContains(t, cs, "k2=")
+		require.NotContains(t, cs, "k1=v1")
+		require.NotContains(t, cs, "k2=v2")
+		require.Contains(t, cs, "k3=v3")
+		require.Equal(t, 1, len(cs))
+	} else {
+		require.Contains(t, cs, "k1=")
+		require.Contains(t, cs, "k2=")
+		require.Contains(t, cs, "k3=v3")
+		require.Equal(t, 3, len(cs))
+	}
+}
+
+func TestSetManyCookies(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := noFollowGet(noRedirectClient(), srv.URL+"/cookies/set-many?count=50")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Equal(t, "/cookies", resp.Header.Get("Location"))
+	cs := resp.Cookies()
+	require.Len(t, cs, 50)
+	require.Equal(t, "c49", cs[49].Name)
+
+	resp, err = noFollowGet(noRedirectClient(), fmt.Sprintf("%s/cookies/set-many?count=%d", srv.URL, httpbin.CookiesMax+1))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Len(t, resp.Cookies(), httpbin.CookiesMax)
+}
+
+func TestValidateCookies(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type cookie struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Valid bool   `json:"valid"`
+		Error string `json:"error"`
 	}
-	require.EqualValues(t, map[string]string{"k1": "v1", "k2": "v2"}, m)
+	var v struct {
+		Valid   bool     `json:"valid"`
+		Cookies []cookie `json:"cookies"`
+	}
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+"/cookies/validate"), &v))
+	require.True(t, v.Valid)
+	require.Empty(t, v.Cookies)
+
+	req, _ := http.NewRequest("GET", srv.URL+"/cookies/validate", nil)
+	req.Header.Set("Cookie", `a=1; b="quoted"; c=has space; d="open; e=x\y; bad name=1; f; g=`)
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.False(t, v.Valid)
+	require.Equal(t, []cookie{
+		{"a", "1", true, ""},
+		{"b", `"quoted"`, true, ""},
+		{"c", "has space", false, `value contains ' '`},
+		{"d", `"open`, false, "value has unbalanced quotes"},
+		{"e", `x\y`, false, `value contains '\\'`},
+		{"bad name", "1", false, "name is not a token"},
+		{"f", "", false, "missing '='"},
+		{"g", "", true, ""},
+	}, v.Cookies)
 }
 
-func TestDeleteCookies(t *testing.T) {
+func TestClearCookies(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
 
@@ -435,33 +2945,16 @@ func TestDeleteCookies(t *testing.T) {
 	cj.SetCookies(u, []*http.Cookie{
 		{Name: "k1", Value: "v1"},
 		{Name: "k2", Value: "v2"},
-		{Name: "k3", Value: "v3"},
 	})
 	cl := noRedirectClient()
 	cl.Jar = cj
-	resp, err := noFollowGet(cl, srv.URL+"/cookies/delete?k1&k2")
+	resp, err := noFollowGet(cl, srv.URL+"/cookies/clear")
 	require.Nil(t, err)
 	defer resp.Body.Close()
 	require.Equal(t, http.StatusFound, resp.StatusCode)
 	require.Equal(t, "/cookies", resp.Header.Get("Location"))
-
-	var cs []string
-	for _, c := range cj.Cookies(u) {
-		cs = append(cs, c.String())
-	}
-	if runtime.Version() >= "go1.8" {
-		require.NotContains(t, cs, "k1=")
-		require.NotContains(t, cs, "k2=")
-		require.NotContains(t, cs, "k1=v1")
-		require.NotContains(t, cs, "k2=v2")
-		require.Contains(t, cs, "k3=v3")
-		require.Equal(t, 1, len(cs))
-	} else {
-		require.Contains(t, cs, "k1=")
-		require.Contains(t, cs, "k2=")
-		require.Contains(t, cs, "k3=v3")
-		require.Equal(t, 3, len(cs))
-	}
+	require.Len(t, resp.Header["Set-Cookie"], 2)
+	require.Empty(t, cj.Cookies(u))
 }
 
 func TestDrip_code(t *testing.T) {
@@ -477,6 +2970,93 @@ func TestDrip_code(t *testing.T) {
 	require.Equal(t, bytes.Repeat([]byte{'*'}, 10), b)
 }
 
+func TestDrip_trailers(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/drip?numbytes=5&duration=0.1&trailers=1")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Len(t, b, 5)
+	require.Equal(t, "5", resp.Trailer.Get("X-Bytes-Sent"))
+	ms, err := strconv.Atoi(resp.Trailer.Get("X-Elapsed-Ms"))
+	require.Nil(t, err)
+	require.True(t, ms >= 0)
+}
+
+func TestDrip_range(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/drip?numbytes=10&duration=0.1", nil)
+	req.Header.Set("Range", "bytes=6-")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+	require.Equal(t, "bytes 6-9/10", resp.Header.Get("Content-Range"))
+	require.EqualValues(t, 4, resp.ContentLength)
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, bytes.Repeat([]byte{'*'}, 4), b)
+
+	req, _ = http.NewRequest("GET", srv.URL+"/drip?numbytes=10&duration=0.1", nil)
+	req.Header.Set("Range", "bytes=10-")
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	require.Equal(t, "bytes */10", resp.Header.Get("Content-Range"))
+}
+
+func TestDrip_offset(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	// the remaining bytes are dripped over the full duration, the body
+	// ending 3 of 4 intervals of 50ms in
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/drip?numbytes=10&duration=0.2&offset=6")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 4, resp.ContentLength)
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, bytes.Repeat([]byte{'*'}, 4), b)
+	elapsed := time.Since(start)
+	require.True(t, elapsed >= 140*time.Millisecond, "dripped in %v", elapsed)
+	require.True(t, elapsed < time.Second, "dripped in %v", elapsed)
+
+	for _, offset := range []string{"11", "-1", "x"} {
+		resp, err := http.Get(srv.URL + "/drip?numbytes=10&duration=0.1&offset=" + offset)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, offset)
+	}
+}
+
+func TestCanonicalizeETag(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	require.JSONEq(t, `{"input": " w/abc", "etag": "W/\"abc\"", "weak": true, "opaque": "abc",
+		"comparison": "weak", "canonical": false}`,
+		string(get(t, srv.URL+"/etag/canonicalize?etag="+url.QueryEscape(" w/abc"))))
+	require.JSONEq(t, `{"input": "\"abc\"", "etag": "\"abc\"", "weak": false, "opaque": "abc",
+		"comparison": "strong", "canonical": true}`,
+		string(get(t, srv.URL+"/etag/canonicalize?etag="+url.QueryEscape(`"abc"`))))
+
+	resp, err := http.Get(srv.URL + "/etag/canonicalize?etag=" + url.QueryEscape(`"a"b"`))
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
 func TestCache_ifModifiedSince(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -514,6 +3094,46 @@ func TestCache_none(t *testing.T) {
 	require.NotEqual(t, int64(0), resp.ContentLength)
 }
 
+func TestVary(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type result struct {
+		Vary    []string          `json:"vary"`
+		Headers map[string]string `json:"headers"`
+		Variant string            `json:"variant"`
+	}
+	fetch := func(accept string) (http.Header, result) {
+		req, _ := http.NewRequest("GET", srv.URL+"/vary?on=accept,x-foo", nil)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var v result
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+		return resp.Header, v
+	}
+
+	hdr, a := fetch("text/html")
+	require.Equal(t, "Accept, X-Foo", hdr.Get("Vary"))
+	require.Equal(t, []string{"Accept", "X-Foo"}, a.Vary)
+	require.Equal(t, map[string]string{"Accept": "text/html", "X-Foo": ""}, a.Headers)
+	require.Len(t, a.Variant, 16)
+
+	_, b := fetch("application/json")
+	require.NotEqual(t, a.Variant, b.Variant)
+	_, c := fetch("text/html")
+	require.Equal(t, a.Variant, c.Variant)
+
+	for _, q := range []string{"", "?on=", "?on=bad%20name"} {
+		resp, err := http.Get(srv.URL + "/vary" + q)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, q)
+	}
+}
+
 func TestSetCache_none(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -551,6 +3171,43 @@ func TestGZIP(t *testing.T) {
 	require.True(t, v.Gzipped)
 }
 
+func TestMaybeGZIP(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	type resp struct {
+		Gzipped bool `json:"gzipped"`
+	}
+
+	cases := []struct {
+		min     int
+		gzipped bool
+	}{
+		{100000, false},
+		{10, true},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("%s/maybe-gzip?min=%d", srv.URL, c.min), nil)
+		req.Header.Set("Accept-Encoding", "gzip") // disable transparent decompression
+		r, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		defer r.Body.Close()
+
+		var body io.Reader = r.Body
+		if c.gzipped {
+			require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+			zr, err := gzip.NewReader(r.Body)
+			require.Nil(t, err)
+			body = zr
+		} else {
+			require.Empty(t, r.Header.Get("Content-Encoding"))
+		}
+		var v resp
+		require.Nil(t, json.NewDecoder(body).Decode(&v))
+		require.Equal(t, c.gzipped, v.Gzipped)
+	}
+}
+
 func TestDeflate(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -595,6 +3252,63 @@ func TestBrotli(t *testing.T) {
 	require.True(t, v.Compressed)
 }
 
+func TestBrotli_options(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/brotli?quality=11&window=16")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var v struct {
+		Compressed bool `json:"compressed"`
+		Quality    int  `json:"quality"`
+		Window     int  `json:"window"`
+	}
+	require.Nil(t, json.NewDecoder(brotli.NewReader(resp.Body)).Decode(&v))
+	require.True(t, v.Compressed)
+	require.Equal(t, 11, v.Quality)
+	require.Equal(t, 16, v.Window)
+
+	for _, q := range []string{"quality=12", "quality=-1", "quality=x", "window=9", "window=25"} {
+		resp, err := http.Get(srv.URL + "/brotli?" + q)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, q)
+	}
+}
+
+func TestGreeting(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	cases := []struct {
+		acceptLanguage string
+		lang, greeting string
+	}{
+		{"", "en", "Hello"},
+		{"es", "es", "Hola"},
+		{"fr;q=0.5, ja", "ja", "こんにちは"},
+		{"xx", "en", "Hello"},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", srv.URL+"/greeting", nil)
+		if c.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", c.acceptLanguage)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, c.lang, resp.Header.Get("Content-Language"), c.acceptLanguage)
+
+		var v struct {
+			Greeting string `json:"greeting"`
+		}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+		require.Equal(t, c.greeting, v.Greeting, c.acceptLanguage)
+	}
+}
+
 func TestRobotsTXT(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -608,6 +3322,23 @@ func TestRobotsTXT(t *testing.T) {
 	require.EqualValues(t, "User-agent: *\nDisallow: /deny\n", string(b))
 }
 
+func TestRobotsTXT_json(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/robots.txt", nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var v struct {
+		Disallow []string `json:"disallow"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, []string{"/deny"}, v.Disallow)
+}
+
 func TestDeny(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -618,6 +3349,187 @@ func TestDeny(t *testing.T) {
 	require.EqualValues(t, "text/plain", resp.Header.Get("Content-Type"))
 }
 
+func TestDeny_json(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/deny", nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var v struct {
+		Message string `json:"message"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.NotEmpty(t, v.Message)
+}
+
+func TestMalformedJSON(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/malformed-json")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var v interface{}
+	require.NotNil(t, json.NewDecoder(resp.Body).Decode(&v))
+}
+
+func TestAcceptParse(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/accept/parse", nil)
+	req.Header.Set("Accept", "text/plain;q=0.5, application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.JSONEq(t, `{"accept": "text/plain;q=0.5, application/json", "entries": [
+		{"type": "application", "subtype": "json", "q": 1},
+		{"type": "text", "subtype": "plain", "q": 0.5}]}`, string(b))
+
+	req.Header.Set("Accept", "text/plain;q=high")
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	b, err = ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Contains(t, string(b), `text/plain;q=high`)
+}
+
+func TestDigestAuth(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	nonceRe := regexp.MustCompile(`nonce="([^"]+)"`)
+	for _, qop := range []string{"auth", "auth-int"} {
+		uri := "/digest-auth/" + qop + "/user/passwd"
+		do := func(auth string) *http.Response {
+			req, _ := http.NewRequest(http.MethodPost, srv.URL+uri, strings.NewReader("body"))
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.Nil(t, err)
+			return resp
+		}
+		authorization := func(nonce, nc, pass string) string {
+			ha1 := md5hex("user:go-httpbin:" + pass)
+			ha2 := md5hex("POST:" + uri)
+			if qop == "auth-int" {
+				ha2 = md5hex("POST:" + uri + ":" + md5hex("body"))
+			}
+			resp := md5hex(ha1 + ":" + nonce + ":" + nc + ":abc:" + qop + ":" + ha2)
+			return fmt.Sprintf(`Digest username="user", realm="go-httpbin", nonce="%s", uri="%s", `+
+				`qop=%s, nc=%s, cnonce="abc", response="%s", algorithm=MD5`, nonce, uri, qop, nc, resp)
+		}
+
+		resp := do("")
+		resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		challenge := resp.Header.Get("WWW-Authenticate")
+		require.True(t, strings.HasPrefix(challenge, "Digest "), challenge)
+		require.Contains(t, challenge, `qop="`+qop+`"`)
+		nonce := nonceRe.FindStringSubmatch(challenge)[1]
+
+		resp = do(authorization(nonce, "00000001", "wrong"))
+		resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode, qop)
+
+		resp = do(authorization(nonce, "00000001", "passwd"))
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, qop)
+		require.JSONEq(t, `{"authenticated": true, "user": "user"}`, string(b))
+
+		resp = do(authorization(nonce, "00000001", "passwd")) // replayed
+		resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode, qop)
+
+		resp = do(authorization(nonce, "00000002", "passwd"))
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, qop)
+
+		resp = do(authorization("unknown", "00000001", "passwd"))
+		resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode, qop)
+		require.Contains(t, resp.Header.Get("WWW-Authenticate"), "stale=true")
+	}
+}
+
+func TestBearer(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for auth, want := range map[string]string{
+		"":                    "Bearer",
+		"Basic dXNlcjpwYXNz":  "Bearer",
+		"Bearer":              "Bearer",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/bearer", nil)
+		req.Header.Set("Authorization", auth)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode, auth)
+		require.Equal(t, want, resp.Header.Get("WWW-Authenticate"), auth)
+	}
+
+	for auth, want := range map[string]string{
+		"bearer abc.DEF-1_2~+/==": `{"authenticated": true, "token": "abc.DEF-1_2~+/==", "valid_format": true}`,
+		"Bearer not a token!":     `{"authenticated": true, "token": "not a token!", "valid_format": false}`,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/bearer", nil)
+		req.Header.Set("Authorization", auth)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, auth)
+		require.JSONEq(t, want, string(b), auth)
+	}
+}
+
+func TestAuthEcho(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	require.JSONEq(t, `{"present": false}`, string(get(t, srv.URL+"/auth/echo")))
+
+	for auth, want := range map[string]string{
+		"Basic " + base64.StdEncoding.EncodeToString([]byte("user:pa:ss")): `{"present": true, "scheme": "Basic",
+			"credentials": "dXNlcjpwYTpzcw==", "user": "user", "password": "pa:ss", "valid_format": true}`,
+		"basic bm9jb2xvbg==":      `{"present": true, "scheme": "basic", "credentials": "bm9jb2xvbg==", "valid_format": false}`,
+		"Basic !!!":               `{"present": true, "scheme": "Basic", "credentials": "!!!", "valid_format": false}`,
+		"Bearer abc.DEF-1_2~+/==": `{"present": true, "scheme": "Bearer", "credentials": "abc.DEF-1_2~+/==", "valid_format": true}`,
+		"Bearer a b":              `{"present": true, "scheme": "Bearer", "credentials": "a b", "valid_format": false}`,
+		"Bearer":                  `{"present": true, "scheme": "Bearer", "valid_format": false}`,
+		`Custom k="v", x=1`:       `{"present": true, "scheme": "Custom", "credentials": "k=\"v\", x=1", "valid_format": true}`,
+		"Bad/Scheme x":            `{"present": true, "scheme": "Bad/Scheme", "credentials": "x", "valid_format": false}`,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/auth/echo", nil)
+		req.Header.Set("Authorization", auth)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Nil(t, err)
+		require.JSONEq(t, want, string(b), auth)
+	}
+}
+
 func TestBasicAuthHandler_noAuth(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -750,6 +3662,115 @@ func TestXML(t *testing.T) {
 		}}, v)
 }
 
+func TestYAML(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/yaml")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/yaml", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	for _, s := range []string{
+		"slideshow:\n",
+		"  title: Sample Slide Show\n",
+		"  date: Date of publication\n",
+		"  author: Yours Truly\n",
+		"    - type: all\n      title: Wake up to WonderWidgets!\n",
+		"    - type: all\n      title: Overview\n",
+	} {
+		require.Contains(t, string(b), s)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/openapi.json")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var v struct {
+		OpenAPI string                 `json:"openapi"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Regexp(t, `^3\.0\.\d+$`, v.OpenAPI)
+	for _, p := range []string{"/get", "/post", "/status/{code}", "/bytes/{n}", "/delay/{n}",
+		"/redirect/{n}", "/cookies", "/gzip", "/image/png"} {
+		require.Contains(t, v.Paths, p)
+	}
+}
+
+func TestOpenAPI_routes(t *testing.T) {
+	srv := httptest.NewServer(httpbin.GetMux(
+		httpbin.WithRequestRecording(10),
+		httpbin.WithDebugStats()))
+	defer srv.Close()
+
+	var spec struct {
+		Routes []struct {
+			Path    string   `json:"path"`
+			Methods []string `json:"methods"`
+			Queries []string `json:"queries"`
+		} `json:"routes"`
+	}
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+"/spec"), &spec))
+
+	type parameter struct {
+		Name string `json:"name"`
+		In   string `json:"in"`
+	}
+	type operation struct {
+		Parameters []parameter `json:"parameters"`
+	}
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.Nil(t, json.Unmarshal(get(t, srv.URL+"/openapi.json"), &doc))
+
+	// path variables are documented without their patterns
+	vars := regexp.MustCompile(`\{(\w+)(?::[^{}]*(?:\{[^{}]*\}[^{}]*)*)?\}`)
+	for _, r := range spec.Routes {
+		path := vars.ReplaceAllString(r.Path, "{$1}")
+		item, ok := doc.Paths[path]
+		require.True(t, ok, "%s is not documented", path)
+
+		var shared []parameter
+		if b, ok := item["parameters"]; ok {
+			require.Nil(t, json.Unmarshal(b, &shared))
+		}
+		methods := r.Methods
+		if len(methods) == 1 && methods[0] == "*" {
+			methods = []string{http.MethodGet} // at least
+		}
+		for _, m := range methods {
+			if m == http.MethodHead {
+				continue
+			}
+			b, ok := item[strings.ToLower(m)]
+			require.True(t, ok, "%s %s is not documented", m, path)
+			var op operation
+			require.Nil(t, json.Unmarshal(b, &op))
+
+			params := make(map[string]bool)
+			for _, p := range append(shared, op.Parameters...) {
+				params[p.In+":"+p.Name] = true
+			}
+			for _, v := range vars.FindAllStringSubmatch(r.Path, -1) {
+				require.True(t, params["path:"+v[1]], "%s %s lacks path parameter %s", m, path, v[1])
+			}
+			for _, q := range r.Queries {
+				name := strings.SplitN(q, "=", 2)[0]
+				require.True(t, params["query:"+name], "%s %s lacks query parameter %s", m, path, name)
+			}
+		}
+	}
+}
+
 func TestJPEG(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -762,6 +3783,123 @@ func TestJPEG(t *testing.T) {
 	require.EqualValues(t, "image/jpeg", resp.Header.Get("Content-Type"))
 }
 
+func TestImage_lastModified(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for _, path := range []string{"/image/png", "/image/jpeg", "/image/gif"} {
+		resp, err := http.Get(srv.URL + path)
+		require.Nil(t, err)
+		resp.Body.Close()
+		lm := resp.Header.Get("Last-Modified")
+		require.NotEmpty(t, lm, path)
+		modtime, err := http.ParseTime(lm)
+		require.Nil(t, err)
+
+		for ims, code := range map[string]int{
+			lm: http.StatusNotModified,
+			modtime.Add(-time.Second).Format(http.TimeFormat): http.StatusOK,
+			"garbage": http.StatusOK,
+		} {
+			req, _ := http.NewRequest("GET", srv.URL+path, nil)
+			req.Header.Set("If-Modified-Since", ims)
+			resp, err := http.DefaultClient.Do(req)
+			require.Nil(t, err)
+			resp.Body.Close()
+			require.Equal(t, code, resp.StatusCode, "%s If-Modified-Since: %s", path, ims)
+		}
+	}
+}
+
+func TestImage_etag(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for _, path := range []string{"/image/png", "/image/jpeg", "/image/gif", "/image/text?seed=1"} {
+		resp, err := http.Get(srv.URL + path)
+		require.Nil(t, err)
+		resp.Body.Close()
+		etag := resp.Header.Get("ETag")
+		require.Regexp(t, `^"[0-9a-f]{16}"$`, etag, path)
+
+		if !strings.HasPrefix(path, "/image/text") { // same size either way
+			req, _ := http.NewRequest("GET", srv.URL+path, nil)
+			req.Header.Set("Save-Data", "on")
+			resp, err = http.DefaultClient.Do(req)
+			require.Nil(t, err)
+			resp.Body.Close()
+			require.NotEqual(t, etag, resp.Header.Get("ETag"), path)
+		}
+
+		for inm, code := range map[string]int{
+			etag:                     http.StatusNotModified,
+			"W/" + etag:              http.StatusNotModified,
+			`"other", ` + etag:       http.StatusNotModified,
+			"*":                      http.StatusNotModified,
+			`"other"`:                http.StatusOK,
+			etag[:len(etag)-2] + `"`: http.StatusOK,
+		} {
+			req, _ := http.NewRequest("GET", srv.URL+path, nil)
+			req.Header.Set("If-None-Match", inm)
+			// If-None-Match takes precedence
+			req.Header.Set("If-Modified-Since", time.Now().Format(http.TimeFormat))
+			resp, err := http.DefaultClient.Do(req)
+			require.Nil(t, err)
+			resp.Body.Close()
+			require.Equal(t, code, resp.StatusCode, "%s If-None-Match: %s", path, inm)
+			require.Equal(t, etag, resp.Header.Get("ETag"), path)
+		}
+	}
+}
+
+func TestImage_saveData(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	for path, sizes := range map[string][2]int{
+		"/image/png":  {512, 128},
+		"/image/jpeg": {512, 128},
+		"/image/gif":  {240, 120},
+	} {
+		for i, saveData := range []string{"", "on"} {
+			req, _ := http.NewRequest("GET", srv.URL+path, nil)
+			if saveData != "" {
+				req.Header.Set("Save-Data", saveData)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.Nil(t, err)
+			cfg, _, err := image.DecodeConfig(resp.Body)
+			resp.Body.Close()
+			require.Nil(t, err, path)
+			require.Equal(t, sizes[i], cfg.Width, "%s Save-Data: %s", path, saveData)
+			require.Contains(t, resp.Header["Vary"], "Save-Data", path)
+		}
+	}
+}
+
+func TestTextImage(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/image/text?content=Hello&seed=3")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	img, _, err := image.Decode(bytes.NewReader(b))
+	require.Nil(t, err)
+	require.Equal(t, image.Rect(0, 0, 512, 512), img.Bounds())
+
+	require.Equal(t, b, get(t, srv.URL+"/image/text?content=Hello&seed=3"))
+	require.NotEqual(t, b, get(t, srv.URL+"/image/text?content=Hello&seed=4"))
+	require.NotEqual(t, b, get(t, srv.URL+"/image/text?content=Hello+world&seed=3"))
+
+	long := strings.Repeat("x", 100)
+	require.Equal(t, get(t, srv.URL+"/image/text?content="+long[:64]),
+		get(t, srv.URL+"/image/text?content="+long))
+}
+
 func TestGIF(t *testing.T) {
 	srv := testServer()
 	defer srv.Close()
@@ -0,0 +1,191 @@
+package httpbin
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// convert returns the key k spelled in style s.
+func (s JSONKeyStyle) convert(k string) string {
+	words := strings.FieldsFunc(k, func(r rune) bool { return r == '-' || r == '_' || r == ' ' })
+	switch s {
+	case JSONKeySnake:
+		return strings.ToLower(strings.Join(words, "_"))
+	case JSONKeyCamel:
+		for i, w := range words {
+			w = strings.ToLower(w)
+			if i > 0 && w != "" {
+				r := []rune(w)
+				r[0] = unicode.ToUpper(r[0])
+				w = string(r)
+			}
+			words[i] = w
+		}
+		return strings.Join(words, "")
+	}
+	return k
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// marshalJSONKeyStyle encodes v like json.Marshal, except that the names of
+// struct fields are converted to the given style. Map keys are left as is,
+// since they carry request data rather than field names.
+func marshalJSONKeyStyle(v interface{}, style JSONKeyStyle) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeJSONKeyStyle(&buf, reflect.ValueOf(v), style); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeJSONKeyStyle(buf *bytes.Buffer, v reflect.Value, style JSONKeyStyle) error {
+	if !v.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) {
+		return encodeJSONValue(buf, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return encodeJSONKeyStyle(buf, v.Elem(), style)
+	case reflect.Struct:
+		buf.WriteByte('{')
+		first := true
+		err := eachJSONField(v, func(name string, fv reflect.Value) error {
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			if err := encodeJSONValue(buf, reflect.ValueOf(style.convert(name))); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			return encodeJSONKeyStyle(buf, fv, style)
+		})
+		buf.WriteByte('}')
+		return err
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return encodeJSONValue(buf, v)
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSONValue(buf, reflect.ValueOf(k.String())); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := encodeJSONKeyStyle(buf, v.MapIndex(k), style); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case reflect.Slice:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 { // base64 like encoding/json
+			return encodeJSONValue(buf, v)
+		}
+		fallthrough
+	case reflect.Array:
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSONKeyStyle(buf, v.Index(i), style); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+	return encodeJSONValue(buf, v)
+}
+
+func encodeJSONValue(buf *bytes.Buffer, v reflect.Value) error {
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+// eachJSONField calls fn with the JSON name and value of each field of
+// struct v that encoding/json would encode, flattening embedded structs.
+func eachJSONField(v reflect.Value, fn func(name string, fv reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if j := strings.Index(tag, ","); j >= 0 {
+			name, opts = tag[:j], tag[j+1:]
+		}
+		fv := v.Field(i)
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			if err := eachJSONField(fv, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyJSONValue(fv) {
+			continue
+		}
+		if err := fn(name, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package httpbin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// writeEchoJSON writes v like writeJSON, unless the request has 'shuffle=1',
+// in which case the keys of every JSON object are written in a pseudo-random
+// order, reproducible with the 'seed' query parameter. An invalid seed is
+// responded with 400.
+func writeEchoJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if r.URL.Query().Get("shuffle") != "1" {
+		return writeJSON(w, v)
+	}
+	seed := time.Now().UnixNano()
+	if s := r.URL.Query().Get("seed"); s != "" {
+		var err error
+		if seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'seed'"))
+			return nil
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSON(keyStyleOf(w, &buf), v); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber() // keep numbers as they were encoded
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return errors.Wrap(err, "failed to decode JSON")
+	}
+	b, err := appendShuffledJSON(nil, tree, rand.New(rand.NewSource(seed)))
+	if err != nil {
+		return errors.Wrap(err, "failed to encode JSON")
+	}
+	buf.Reset()
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return errors.Wrap(err, "failed to encode JSON")
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// keyStyleOf returns buf, reporting the JSON key style of w to writeJSON if
+// w has one.
+func keyStyleOf(w http.ResponseWriter, buf *bytes.Buffer) io.Writer {
+	if ks, ok := w.(interface {
+		jsonKeyStyle() JSONKeyStyle
+	}); ok {
+		return keyStyleBuffer{buf, ks.jsonKeyStyle()}
+	}
+	return buf
+}
+
+type keyStyleBuffer struct {
+	*bytes.Buffer
+	style JSONKeyStyle
+}
+
+func (b keyStyleBuffer) jsonKeyStyle() JSONKeyStyle { return b.style }
+
+// appendShuffledJSON appends the JSON encoding of v, as decoded by
+// encoding/json, to b with the keys of objects in an order chosen by rnd.
+func appendShuffledJSON(b []byte, v interface{}, rnd *rand.Rand) ([]byte, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys) // start from a stable order so the seed decides
+		rnd.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		b = append(b, '{')
+		for i, k := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			kb, err := json.Marshal(k)
+			if err != nil {
+				return nil, err
+			}
+			b = append(append(b, kb...), ':')
+			if b, err = appendShuffledJSON(b, v[k], rnd); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, '}'), nil
+	case []interface{}:
+		b = append(b, '[')
+		for i, e := range v {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			var err error
+			if b, err = appendShuffledJSON(b, e, rnd); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	default:
+		vb, err := json.Marshal(v)
+		return append(b, vb...), err
+	}
+}
@@ -0,0 +1,277 @@
+package httpbin
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// rawRecordMax bounds the bytes of request heads kept for each
+	// connection accepted by Listener, longer heads are not kept.
+	rawRecordMax = 64 * 1024
+
+	// rawHeadsMax is the number of request heads kept for each connection
+	// accepted by Listener, which only matters for pipelined requests.
+	rawHeadsMax = 16
+)
+
+// Listener wraps l so that the raw request heads received on its connections
+// are recorded, which is required by /headers/ordered along with ConnContext.
+// Request bodies are skipped, not recorded.
+func Listener(l net.Listener) net.Listener {
+	return &recordingListener{l}
+}
+
+type recordingListener struct {
+	net.Listener
+}
+
+func (l *recordingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{Conn: c}, nil
+}
+
+// States of a recordingConn following the framing of the requests read.
+const (
+	readHead      = iota // request line and header fields
+	readBody             // Content-Length body
+	readChunkSize        // chunk size line of a chunked body
+	readChunk            // chunk data and its CRLF
+	readTrailer          // trailer section of a chunked body
+	readOff              // not HTTP/1.x anymore, nothing is recorded
+)
+
+// recordingConn keeps the most recent request heads read from the connection
+// in a ring, following the framing of the requests to skip their bodies.
+type recordingConn struct {
+	net.Conn
+
+	mu    sync.Mutex
+	heads [rawHeadsMax][]byte // ring of complete heads, oldest at first
+	first int
+	n     int // number of heads in the ring
+	size  int // total bytes of the heads in the ring
+
+	state     int
+	cur       []byte // current head, or chunk line, read so far
+	lineStart int    // offset of the current line in cur
+	lines     int    // complete lines of the current head
+	truncated bool   // the current head is too long to keep
+	preface   bool   // the current head is the HTTP/2 connection preface
+	chunked   bool
+	length    int64 // Content-Length of the current head
+	remaining int64 // bytes of the body or chunk left to skip
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.mu.Lock()
+	c.record(b[:n])
+	c.mu.Unlock()
+	return n, err
+}
+
+// record follows the requests in b, keeping their heads and skipping their
+// bodies. c.mu must be held.
+func (c *recordingConn) record(b []byte) {
+	for len(b) > 0 && c.state != readOff {
+		if c.state == readBody || c.state == readChunk {
+			n := int64(len(b))
+			if n > c.remaining {
+				n = c.remaining
+			}
+			b = b[n:]
+			if c.remaining -= n; c.remaining == 0 {
+				if c.state == readBody {
+					c.state = readHead
+				} else {
+					c.state = readChunkSize
+				}
+			}
+			continue
+		}
+
+		line := b
+		i := bytes.IndexByte(b, '\n')
+		if i >= 0 {
+			line = b[:i+1]
+		}
+		b = b[len(line):]
+		if len(c.cur)+len(line) > rawRecordMax && c.state == readHead && !c.truncated {
+			// too long to keep, only its framing is followed from here on
+			c.truncated = true
+			c.cur = append(c.cur[:0], c.cur[c.lineStart:]...)
+			c.lineStart = 0
+		}
+		if len(c.cur)+len(line) <= rawRecordMax {
+			c.cur = append(c.cur, line...)
+		}
+		if i >= 0 {
+			c.endLine()
+		}
+	}
+}
+
+// endLine handles the line at the end of c.cur. c.mu must be held.
+func (c *recordingConn) endLine() {
+	line := bytes.TrimRight(c.cur[c.lineStart:], "\r\n")
+	switch c.state {
+	case readHead:
+		if len(line) > 0 {
+			if c.lines == 0 {
+				c.preface = string(line) == "PRI * HTTP/2.0"
+			} else {
+				c.header(line)
+			}
+			c.lines++
+			if c.truncated {
+				c.cur = c.cur[:0]
+			}
+			c.lineStart = len(c.cur)
+			return
+		}
+		if c.lines > 0 { // blank lines before a request are ignored
+			c.endHead()
+		}
+	case readChunkSize:
+		if i := bytes.IndexByte(line, ';'); i >= 0 {
+			line = line[:i] // chunk extensions
+		}
+		size, err := strconv.ParseInt(string(bytes.TrimSpace(line)), 16, 64)
+		switch {
+		case err != nil || size < 0:
+			c.state = readOff // net/http gives up on the connection too
+		case size == 0:
+			c.state = readTrailer
+		default:
+			c.state, c.remaining = readChunk, size+2 // and CRLF
+		}
+	case readTrailer:
+		if len(line) == 0 {
+			c.state = readHead
+		}
+	}
+	c.cur, c.lineStart = c.cur[:0], 0
+}
+
+// header notes the framing of the body given by the header field line.
+func (c *recordingConn) header(line []byte) {
+	k := bytes.IndexByte(line, ':')
+	if k <= 0 {
+		return
+	}
+	name, value := line[:k], bytes.TrimSpace(line[k+1:])
+	switch {
+	case bytes.EqualFold(name, []byte("Content-Length")):
+		c.length, _ = strconv.ParseInt(string(value), 10, 64)
+	case bytes.EqualFold(name, []byte("Transfer-Encoding")):
+		c.chunked = bytes.EqualFold(value, []byte("chunked"))
+	}
+}
+
+// endHead keeps the head just read and moves on to its body. c.mu must be
+// held.
+func (c *recordingConn) endHead() {
+	if !c.truncated && !c.preface {
+		c.push(append([]byte(nil), c.cur...))
+	}
+	switch {
+	case c.preface:
+		c.state = readOff
+	case c.chunked:
+		c.state = readChunkSize
+	case c.length > 0:
+		c.state, c.remaining = readBody, c.length
+	default:
+		c.state = readHead
+	}
+	c.lines, c.truncated, c.preface, c.chunked, c.length = 0, false, false, false, 0
+}
+
+// push adds head to the ring, dropping the oldest heads to stay within
+// rawHeadsMax heads and rawRecordMax bytes. c.mu must be held.
+func (c *recordingConn) push(head []byte) {
+	for c.n > 0 && (c.n == rawHeadsMax || c.size+len(head) > rawRecordMax) {
+		c.pop()
+	}
+	c.heads[(c.first+c.n)%rawHeadsMax] = head
+	c.n++
+	c.size += len(head)
+}
+
+// pop removes and returns the oldest head of the ring. c.mu must be held.
+func (c *recordingConn) pop() []byte {
+	head := c.heads[c.first]
+	c.heads[c.first] = nil
+	c.first = (c.first + 1) % rawHeadsMax
+	c.n--
+	c.size -= len(head)
+	return head
+}
+
+// rawHeaders returns the header fields of the oldest recorded head starting
+// with requestLine as [name, value] pairs, in the order and spelling they were
+// sent. As requests on a connection are handled in the order received, the
+// head and those before it are forgotten, so that pipelined requests with the
+// same request line each get their own. It reports false if the request head
+// is not recorded.
+func (c *recordingConn) rawHeaders(requestLine string) ([][2]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := 0; i < c.n; i++ {
+		rest, ok := afterLine(c.heads[(c.first+i)%rawHeadsMax], requestLine)
+		if !ok {
+			continue
+		}
+		for ; i > 0; i-- {
+			c.pop()
+		}
+		c.pop()
+		return parseRawHeaders(rest), true
+	}
+	return nil, false
+}
+
+// afterLine returns what follows the first line of b if it is line, ended by
+// CRLF or, as net/http accepts, a bare LF.
+func afterLine(b []byte, line string) ([]byte, bool) {
+	if !bytes.HasPrefix(b, []byte(line)) {
+		return nil, false
+	}
+	rest := b[len(line):]
+	if len(rest) > 0 && rest[0] == '\r' {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 || rest[0] != '\n' {
+		return nil, false
+	}
+	return rest[1:], true
+}
+
+// parseRawHeaders returns the header fields in the complete request head b,
+// following its request line, as [name, value] pairs. Lines may end with CRLF
+// or a bare LF.
+func parseRawHeaders(b []byte) [][2]string {
+	hdrs := [][2]string{}
+	for {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			return hdrs
+		}
+		field := bytes.TrimSuffix(b[:i], []byte("\r"))
+		b = b[i+1:]
+		if len(field) == 0 {
+			return hdrs
+		}
+		if k := bytes.IndexByte(field, ':'); k > 0 {
+			hdrs = append(hdrs, [2]string{string(field[:k]), strings.TrimSpace(string(field[k+1:]))})
+		}
+	}
+}
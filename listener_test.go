@@ -0,0 +1,101 @@
+package httpbin
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingConn(t *testing.T) {
+	c := &recordingConn{}
+	stream := "GET /a HTTP/1.1\r\nHost: x\r\nX-N: 1\r\n\r\n" +
+		"POST /a HTTP/1.1\r\nHost: x\r\nContent-Length: 36\r\n\r\n" +
+		"GET /a HTTP/1.1\r\nHost: x\r\nX-N: 2\r\n\r\n" + // the body
+		"POST /a HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"11;ext=1\r\nGET /a HTTP/1.1\r\n\r\n" + "0\r\nX-Trailer: 1\r\n\r\n" +
+		"\r\nGET /a HTTP/1.1\r\nHost: x\r\nX-N: 3\r\n\r\n"
+	// in pieces splitting lines
+	for len(stream) > 0 {
+		n := 7
+		if n > len(stream) {
+			n = len(stream)
+		}
+		c.record([]byte(stream[:n]))
+		stream = stream[n:]
+	}
+	require.Equal(t, 4, c.n, "bodies must not be recorded")
+
+	for _, n := range []string{"1", "3"} {
+		hdrs, ok := c.rawHeaders("GET /a HTTP/1.1")
+		require.True(t, ok)
+		require.Equal(t, [][2]string{{"Host", "x"}, {"X-N", n}}, hdrs)
+	}
+	_, ok := c.rawHeaders("GET /a HTTP/1.1")
+	require.False(t, ok)
+	require.Equal(t, 0, c.n, "earlier heads are forgotten")
+	require.Equal(t, 0, c.size)
+}
+
+func TestRecordingConn_bareLF(t *testing.T) {
+	c := &recordingConn{}
+	c.record([]byte("GET /a HTTP/1.1\nHost: x\r\nX-N: 1\n\n" +
+		"POST /a HTTP/1.1\nHost: x\nTransfer-Encoding: chunked\n\n" +
+		"3\r\nGET\r\n" + "0\r\nX-Sum: 1\nX-Sum: 2\r\n\n" +
+		"GET /a HTTP/1.1\r\nHost: x\nX-N: 2\r\n\r\n"))
+	require.Equal(t, readHead, c.state)
+	require.Equal(t, 3, c.n, "trailers must not be recorded")
+
+	hdrs, ok := c.rawHeaders("GET /a HTTP/1.1")
+	require.True(t, ok)
+	require.Equal(t, [][2]string{{"Host", "x"}, {"X-N", "1"}}, hdrs)
+	hdrs, ok = c.rawHeaders("POST /a HTTP/1.1")
+	require.True(t, ok)
+	require.Equal(t, [][2]string{{"Host", "x"}, {"Transfer-Encoding", "chunked"}}, hdrs)
+	hdrs, ok = c.rawHeaders("GET /a HTTP/1.1")
+	require.True(t, ok)
+	require.Equal(t, [][2]string{{"Host", "x"}, {"X-N", "2"}}, hdrs)
+
+	// only the whole request line matches
+	c.record([]byte("GET /a HTTP/1.1x\n\n"))
+	_, ok = c.rawHeaders("GET /a HTTP/1.1")
+	require.False(t, ok)
+}
+
+func TestRecordingConn_limits(t *testing.T) {
+	c := &recordingConn{}
+	body := strings.Repeat("x", 10*rawRecordMax)
+	c.record([]byte("POST / HTTP/1.1\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body))
+	require.Equal(t, readHead, c.state)
+	require.True(t, c.size < 100, "recorded %d bytes", c.size)
+
+	// heads too long to keep are still followed
+	c.record([]byte("GET /long HTTP/1.1\r\nX-Long: " + strings.Repeat("x", rawRecordMax) + "\r\nContent-Length: 3\r\n\r\nabc"))
+	require.Equal(t, readHead, c.state)
+	_, ok := c.rawHeaders("GET /long HTTP/1.1")
+	require.False(t, ok)
+	require.True(t, cap(c.cur) <= 2*rawRecordMax)
+
+	// as are those over rawRecordMax in pieces, and the next head is kept
+	c.record([]byte("GET /long HTTP/1.1\r\n"))
+	for i := 0; i < 3; i++ {
+		c.record([]byte("X-Long: " + strings.Repeat("x", rawRecordMax/2) + "\r\n"))
+	}
+	c.record([]byte("\r\nGET /next HTTP/1.1\r\nHost: x\r\n\r\n"))
+	_, ok = c.rawHeaders("GET /long HTTP/1.1")
+	require.False(t, ok)
+	hdrs, ok := c.rawHeaders("GET /next HTTP/1.1")
+	require.True(t, ok)
+	require.Equal(t, [][2]string{{"Host", "x"}}, hdrs)
+
+	for i := 0; i < 2*rawHeadsMax; i++ {
+		c.record([]byte("GET / HTTP/1.1\r\n\r\n"))
+	}
+	require.Equal(t, rawHeadsMax, c.n)
+
+	// nothing is recorded after the HTTP/2 connection preface
+	c.record([]byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\nGET / HTTP/1.1\r\n\r\n"))
+	require.Equal(t, readOff, c.state)
+	require.Equal(t, rawHeadsMax, c.n)
+}
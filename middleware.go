@@ -0,0 +1,295 @@
+package httpbin
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// responseWriter passes the optional interfaces of the wrapped
+// http.ResponseWriter through, so that wrapping it in a middleware does not
+// break streaming or hijacking handlers.
+type responseWriter struct {
+	http.ResponseWriter
+}
+
+func (w responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	return h.Hijack()
+}
+
+func (w responseWriter) EnableFullDuplex() error {
+	fd, ok := w.ResponseWriter.(interface {
+		EnableFullDuplex() error
+	})
+	if !ok {
+		return errors.New("full duplex not supported")
+	}
+	return fd.EnableFullDuplex()
+}
+
+func (w responseWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, as expected by
+// http.ResponseController and unwrapResponseWriter.
+func (w responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// unwrapResponseWriter returns the innermost http.ResponseWriter wrapped by
+// the middlewares, to check which optional interfaces the server supports.
+func unwrapResponseWriter(w http.ResponseWriter) http.ResponseWriter {
+	for {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return w
+		}
+		w = u.Unwrap()
+	}
+}
+
+// defaultHeadersMiddleware copies the given headers onto the response before
+// the handler runs, so that any header the handler sets replaces the default.
+func defaultHeadersMiddleware(h http.Header) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for k, v := range h {
+				w.Header()[k] = append([]string(nil), v...)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// responseTimeMiddleware reports the handler processing time in the
+// X-Response-Time header. Since headers precede the body, the time is taken
+// when the handler first writes the status or body, not when it returns.
+func responseTimeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := &responseTimeWriter{responseWriter: responseWriter{w}, start: time.Now()}
+		next.ServeHTTP(ww, r)
+		if ww.status == 0 { // handler didn't write anything
+			ww.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// responseTimeWriter captures the status code and sets the X-Response-Time
+// header right before the status is written.
+type responseTimeWriter struct {
+	responseWriter
+	start  time.Time
+	status int
+}
+
+func (w *responseTimeWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		ms := float64(time.Since(w.start)) / float64(time.Millisecond)
+		w.Header().Set("X-Response-Time", strconv.FormatFloat(ms, 'f', 3, 64))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseTimeWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+type ctxKey int
+
+const (
+	noCompressionKey ctxKey = iota
+	connCounterKey
+	rawConnKey
+	receivedAtKey
+)
+
+// ConnContext is meant to be set as the ConnContext of the http.Server
+// serving the mux. It attaches a per-connection request counter to the
+// context, which is required by /conn-count, and the recorded connection if
+// it was accepted by Listener.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	if rc, ok := c.(*recordingConn); ok {
+		ctx = context.WithValue(ctx, rawConnKey, rc)
+	}
+	return context.WithValue(ctx, connCounterKey, new(int64))
+}
+
+// receivedAtMiddleware records in the request context when the mux started
+// handling the request, for /timing.
+func receivedAtMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), receivedAtKey, time.Now())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// connCounterMiddleware counts the requests served on each connection tracked
+// by ConnContext.
+func connCounterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, ok := r.Context().Value(connCounterKey).(*int64); ok {
+			atomic.AddInt64(c, 1)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// disableCompression prevents compressionMiddleware from compressing the
+// response to r. Streaming handlers call it before writing so that each
+// flushed write reaches the client as it happens instead of being buffered by
+// the compressor.
+func disableCompression(r *http.Request) {
+	if v, ok := r.Context().Value(noCompressionKey).(*bool); ok {
+		*v = true
+	}
+}
+
+// compressionMiddleware gzip-encodes responses for clients accepting gzip,
+// unless the handler already set a Content-Encoding or called
+// disableCompression.
+func compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ww := &gzipResponseWriter{responseWriter: responseWriter{w}}
+		r = r.WithContext(context.WithValue(r.Context(), noCompressionKey, &ww.disabled))
+		next.ServeHTTP(ww, r)
+		ww.Close()
+	})
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r accepts gzip
+// with a non-zero weight, by name or else through "*". Codings with a
+// malformed weight are ignored.
+func acceptsGzip(r *http.Request) bool {
+	gzipQ, anyQ := -1.0, -1.0 // not listed
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(v, ";")
+		q, ok := 1.0, true
+		for _, p := range parts[1:] {
+			kv := strings.SplitN(p, "=", 2)
+			if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), "q") {
+				w := strings.TrimSpace(kv[1])
+				ok = qvalue.MatchString(w)
+				q, _ = strconv.ParseFloat(w, 64)
+			}
+		}
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case "gzip":
+			gzipQ = q
+		case "*":
+			anyQ = q
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
+
+// gzipResponseWriter decides whether to compress when the status is written
+// and compresses the body with gzip if so.
+type gzipResponseWriter struct {
+	responseWriter
+	gz          *gzip.Writer
+	disabled    bool
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if !w.disabled && h.Get("Content-Encoding") == "" &&
+		code != http.StatusNoContent && code != http.StatusNotModified {
+		h.Set("Content-Encoding", "gzip")
+		h.Add("Vary", "Accept-Encoding")
+		h.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.responseWriter.Flush()
+}
+
+func (w *gzipResponseWriter) Close() error {
+	if w.gz == nil {
+		return nil
+	}
+	return w.gz.Close()
+}
+
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.wroteHeader = true // connection is no longer ours to write to
+	return w.responseWriter.Hijack()
+}
+
+// jsonKeyStyleMiddleware makes writeJSON spell the keys of response objects
+// in the given style. It must be the innermost middleware, since writeJSON
+// finds the style on the http.ResponseWriter passed to the handler.
+func jsonKeyStyleMiddleware(style JSONKeyStyle) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(jsonKeyStyleWriter{responseWriter{w}, style}, r)
+		})
+	}
+}
+
+type jsonKeyStyleWriter struct {
+	responseWriter
+	style JSONKeyStyle
+}
+
+func (w jsonKeyStyleWriter) jsonKeyStyle() JSONKeyStyle { return w.style }
@@ -0,0 +1,90 @@
+package httpbin
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// marshalMsgpack encodes the slideshow as MessagePack maps keyed like its
+// JSON encoding, leaving out empty items the same way.
+func (v slideshowResponse) marshalMsgpack() []byte {
+	s := v.Slideshow
+	b := appendMsgpackMapHeader(nil, 1)
+	b = appendMsgpackString(b, "slideshow")
+	b = appendMsgpackMapHeader(b, 4)
+	b = appendMsgpackString(b, "title")
+	b = appendMsgpackString(b, s.Title)
+	b = appendMsgpackString(b, "date")
+	b = appendMsgpackString(b, s.Date)
+	b = appendMsgpackString(b, "author")
+	b = appendMsgpackString(b, s.Author)
+	b = appendMsgpackString(b, "slides")
+	b = appendMsgpackArrayHeader(b, len(s.Slides))
+	for _, sl := range s.Slides {
+		n := 2
+		if len(sl.Items) > 0 {
+			n++
+		}
+		b = appendMsgpackMapHeader(b, n)
+		b = appendMsgpackString(b, "type")
+		b = appendMsgpackString(b, sl.Type)
+		b = appendMsgpackString(b, "title")
+		b = appendMsgpackString(b, sl.Title)
+		if len(sl.Items) > 0 {
+			b = appendMsgpackString(b, "items")
+			b = appendMsgpackArrayHeader(b, len(sl.Items))
+			for _, it := range sl.Items {
+				b = appendMsgpackString(b, it)
+			}
+		}
+	}
+	return b
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendMsgpackUint16(append(b, 0xda), uint16(n))
+	default:
+		b = appendMsgpackUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendMsgpackUint16(append(b, 0xdc), uint16(n))
+	default:
+		return appendMsgpackUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendMsgpackUint16(append(b, 0xde), uint16(n))
+	default:
+		return appendMsgpackUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+func appendMsgpackUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendMsgpackUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
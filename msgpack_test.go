@@ -0,0 +1,151 @@
+package httpbin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// decodeMsgpack decodes the maps, arrays and strings marshalMsgpack writes
+// into the values encoding/json would decode the same document to.
+func decodeMsgpack(b []byte) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("unexpected end of input")
+	}
+	c, b := b[0], b[1:]
+	length := func(size int) (int, error) {
+		if len(b) < size {
+			return 0, fmt.Errorf("unexpected end of input")
+		}
+		var n int
+		switch size {
+		case 1:
+			n = int(b[0])
+		case 2:
+			n = int(binary.BigEndian.Uint16(b))
+		case 4:
+			n = int(binary.BigEndian.Uint32(b))
+		}
+		b = b[size:]
+		return n, nil
+	}
+	var (
+		n    int
+		err  error
+		kind byte
+	)
+	switch {
+	case c&0xe0 == 0xa0:
+		n, kind = int(c&0x1f), 's'
+	case c&0xf0 == 0x90:
+		n, kind = int(c&0x0f), 'a'
+	case c&0xf0 == 0x80:
+		n, kind = int(c&0x0f), 'm'
+	case c == 0xd9, c == 0xda, c == 0xdb:
+		n, err = length(1 << (c - 0xd9))
+		kind = 's'
+	case c == 0xdc, c == 0xdd:
+		n, err = length(2 << (c - 0xdc))
+		kind = 'a'
+	case c == 0xde, c == 0xdf:
+		n, err = length(2 << (c - 0xde))
+		kind = 'm'
+	default:
+		return nil, nil, fmt.Errorf("unsupported type 0x%02x", c)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	switch kind {
+	case 's':
+		if len(b) < n {
+			return nil, nil, fmt.Errorf("unexpected end of input")
+		}
+		return string(b[:n]), b[n:], nil
+	case 'a':
+		a := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			var v interface{}
+			if v, b, err = decodeMsgpack(b); err != nil {
+				return nil, nil, err
+			}
+			a = append(a, v)
+		}
+		return a, b, nil
+	default:
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			var k, v interface{}
+			if k, b, err = decodeMsgpack(b); err != nil {
+				return nil, nil, err
+			}
+			if v, b, err = decodeMsgpack(b); err != nil {
+				return nil, nil, err
+			}
+			s, ok := k.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("map key %v is not a string", k)
+			}
+			m[s] = v
+		}
+		return m, b, nil
+	}
+}
+
+func TestSlideshowMarshalMsgpack(t *testing.T) {
+	b := slideshowResponse{Slideshow: slideshow{
+		Title:  "t",
+		Slides: []slide{{Type: "all", Items: []string{"i"}}, {}},
+	}}.marshalMsgpack()
+	require.Equal(t, []byte{
+		0x81, 0xa9, 's', 'l', 'i', 'd', 'e', 's', 'h', 'o', 'w',
+		0x84,
+		0xa5, 't', 'i', 't', 'l', 'e', 0xa1, 't',
+		0xa4, 'd', 'a', 't', 'e', 0xa0,
+		0xa6, 'a', 'u', 't', 'h', 'o', 'r', 0xa0,
+		0xa6, 's', 'l', 'i', 'd', 'e', 's', 0x92,
+		0x83,
+		0xa4, 't', 'y', 'p', 'e', 0xa3, 'a', 'l', 'l',
+		0xa5, 't', 'i', 't', 'l', 'e', 0xa0,
+		0xa5, 'i', 't', 'e', 'm', 's', 0x91, 0xa1, 'i',
+		0x82,
+		0xa4, 't', 'y', 'p', 'e', 0xa0,
+		0xa5, 't', 'i', 't', 'l', 'e', 0xa0,
+	}, b)
+}
+
+func TestAppendMsgpackString(t *testing.T) {
+	cases := []struct {
+		n      int
+		header []byte
+	}{
+		{0, []byte{0xa0}},
+		{31, []byte{0xbf}},
+		{32, []byte{0xd9, 0x20}},
+		{256, []byte{0xda, 0x01, 0x00}},
+		{1 << 16, []byte{0xdb, 0, 1, 0, 0}},
+	}
+	for _, c := range cases {
+		s := strings.Repeat("x", c.n)
+		require.Equal(t, append(c.header, s...), appendMsgpackString(nil, s), "%d", c.n)
+	}
+}
+
+func TestMsgpackHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	JSONHandler(w, httptest.NewRequest("GET", "/json", nil))
+	var want interface{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &want))
+
+	w = httptest.NewRecorder()
+	MsgpackHandler(w, httptest.NewRequest("GET", "/msgpack", nil))
+	got, rest, err := decodeMsgpack(w.Body.Bytes())
+	require.Nil(t, err)
+	require.Empty(t, rest)
+	require.Equal(t, want, got)
+}
@@ -0,0 +1,373 @@
+package httpbin
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// apiPath documents a path in the OpenAPI document served by /openapi.json.
+// The methods, path parameters and required query parameters come from the
+// route itself.
+type apiPath struct {
+	summary     string
+	status      string     // of successful responses, "200" if empty
+	contentType string     // of successful responses, application/json if empty, "-" for none
+	params      []apiParam // optional query and header parameters
+}
+
+type apiParam struct {
+	name, in, typ, format, description string
+	enum                               []string
+}
+
+func queryParam(name, typ, description string) apiParam {
+	return apiParam{name: name, in: "query", typ: typ, description: description}
+}
+
+func headerParam(name, typ, description string) apiParam {
+	return apiParam{name: name, in: "header", typ: typ, description: description}
+}
+
+func (p apiParam) withFormat(format string) apiParam { p.format = format; return p }
+
+func (p apiParam) withEnum(values ...string) apiParam { p.enum = values; return p }
+
+var (
+	seedParam     = queryParam("seed", "integer", "Seed of the random generator.").withFormat("int64")
+	shuffleParam  = queryParam("shuffle", "string", "Set to 1 to randomly order the keys of the response objects.")
+	echoParams    = []apiParam{shuffleParam, queryParam("seed", "integer", "Seed of the key order with shuffle=1.").withFormat("int64")}
+	saveDataParam = headerParam("Save-Data", "string", "With on, a smaller response is returned.")
+)
+
+// apiPaths documents the paths registered by GetMux, keyed by their path
+// templates without the patterns of the variables.
+var apiPaths = map[string]apiPath{
+	"/":                 {summary: "Returns the home page.", contentType: "text/html"},
+	"/ip":               {summary: "Returns the origin IP, as plain text with Accept: text/plain."},
+	"/hostname":         {summary: "Returns the hostname of the server."},
+	"/user-agent":       {summary: "Returns the user agent."},
+	"/user-agent/parse": {summary: "Returns the browser, OS and device type parsed from the user agent."},
+	"/http-version":     {summary: "Returns the HTTP protocol version of the request."},
+	"/alpn":             {summary: "Returns the protocol negotiated with TLS ALPN."},
+	"/h2-info":          {summary: "Returns the request protocol and whether the server supports HTTP/2 server push."},
+	"/geo":              {summary: "Returns a canned location for the country of the CF-IPCountry or X-Country header."},
+	"/self":             {summary: "Returns the base URL the server was addressed with."},
+	"/limits":           {summary: "Returns the limits enforced by the endpoints."},
+	"/headers":          {summary: "Returns the request headers."},
+	"/headers/redacted": {summary: "Returns the request headers with credentials masked.", params: []apiParam{
+		queryParam("redact", "string", "Comma-separated names of further headers to mask.")}},
+	"/headers/ordered": {summary: "Returns the request headers in the order received."},
+	"/dup-headers": {summary: "Returns how many fields of some headers the request had and their values.", params: []apiParam{
+		queryParam("names", "string", "Comma-separated header names, Content-Length, Host and Transfer-Encoding by default.")}},
+	"/hop-by-hop": {summary: "Returns the hop-by-hop headers received.", params: []apiParam{
+		queryParam("strip", "string", "Set to 1 to also return the end-to-end headers.")}},
+	"/method": {summary: "Returns the request method and the effective method after override headers.", params: []apiParam{
+		queryParam("_method", "string", "Method override.")}},
+	"/proxy-echo":   {summary: "Returns the request target and parsed URL."},
+	"/request-line": {summary: "Returns the request line exactly as received."},
+	"/scheme":       {summary: "Returns the scheme of the request and where it was derived from."},
+	"/timing":       {summary: "Reads the request body and returns when the request was received and responded to."},
+	"/resolve": {summary: "Decodes and cleans a path, returning each form and whether cleaning changed it.", params: []apiParam{
+		queryParam("target", "string", "Path to resolve.")}},
+	"/get": {summary: "Returns the request's query arguments, headers and origin.", params: append([]apiParam{
+		queryParam("show_headers", "string", "Comma-separated header names to limit the echoed headers to."),
+		queryParam("proxy_info", "string", "Set to 1 to report whether the request was proxied and its Via chain.")},
+		echoParams...)},
+	"/post": {summary: "Returns the request's query arguments, headers, origin and body.", params: append([]apiParam{
+		queryParam("merge", "string", "Set to 1 to include the query arguments merged with the form body as params.")},
+		echoParams...)},
+	"/put":             {summary: "Returns the request's query arguments, headers, origin and body like /post.", params: echoParams},
+	"/patch":           {summary: "Returns the request's query arguments, headers, origin and body like /post.", params: echoParams},
+	"/delete":          {summary: "Returns the request's query arguments, headers, origin and body like /post.", params: echoParams},
+	"/anything":        {summary: "Returns the request data for any method, with form and JSON bodies parsed."},
+	"/anything/{path}": {summary: "Returns the request data for any method, with form and JSON bodies parsed."},
+	"/post/stream":     {summary: "Streams back each element of a POSTed JSON array as a line of NDJSON.", contentType: "application/x-ndjson"},
+	"/stream-upload": {summary: "Reads a streamed NDJSON body and acknowledges every k lines.", contentType: "application/x-ndjson", params: []apiParam{
+		queryParam("every", "integer", "Lines per acknowledgement.")}},
+	"/verify-hmac": {summary: "Verifies the HMAC-SHA256 of the body against a header.", params: []apiParam{
+		queryParam("secret", "string", "HMAC key."),
+		queryParam("header", "string", "Header with the signature, X-Signature by default."),
+		queryParam("encoding", "string", "Encoding of the signature.").withEnum("hex", "base64")}},
+	"/verify-length": {summary: "Compares the declared Content-Length of the body with the bytes received."},
+	"/upload-trailer": {summary: "Verifies the SHA-256 of a chunked body against a trailer.", params: []apiParam{
+		queryParam("trailer", "string", "Trailer with the digest, X-Checksum-Sha256 by default.")}},
+	"/redirect/{n}":          {summary: "Redirects n times.", status: "302", contentType: "-"},
+	"/absolute-redirect/{n}": {summary: "Redirects n times with absolute URLs.", status: "302", contentType: "-"},
+	"/redirect-post": {summary: "Redirects a POST with the given 3xx status.", status: "302", params: []apiParam{
+		queryParam("status", "integer", "3xx status of the redirect."),
+		queryParam("url", "string", "Redirect target, /get by default.")}},
+	"/redirect-preserve": {summary: "Redirects to the given URL, optionally keeping the query.", status: "302", contentType: "-", params: []apiParam{
+		queryParam("to", "string", "Redirect target, /get by default."),
+		queryParam("keep_query", "string", "Set to 1 to append the rest of the query.")}},
+	"/redirect-to": {summary: "Redirects to the given URL.", status: "302", contentType: "-"},
+	"/status/{code}": {summary: "Returns the given status code.", status: "default", contentType: "text/plain", params: []apiParam{
+		queryParam("body", "string", "Text to return as the body."),
+		queryParam("content_type", "string", "Content-Type of body, text/plain by default. Only text/plain, text/csv, application/json, application/*+json and application/octet-stream are allowed.")}},
+	"/no-content": {summary: "Returns 204 No Content without a body.", status: "204", contentType: "-"},
+	"/reset": {summary: "Abruptly closes the connection before or after writing a partial response.", status: "default", contentType: "-", params: []apiParam{
+		queryParam("stage", "string", "When to close the connection.").withEnum("before", "after")}},
+	"/conn-count": {summary: "Returns how many requests the connection has served."},
+	"/keepalive":  {summary: "Returns the read, write and idle timeouts of the server in seconds."},
+	"/bytes/{n}": {summary: "Returns min(n, BytesMax) random bytes.", contentType: "application/octet-stream", params: []apiParam{
+		seedParam,
+		queryParam("entropy", "string", "Set to low for long runs of repeated bytes that compress well.").withEnum("high", "low"),
+		headerParam("X-Seed", "integer", "Seed of the random generator if the seed parameter is absent.").withFormat("int64"),
+		saveDataParam}},
+	"/magic/{type}": {summary: "Returns bytes starting with the magic signature of a file type.", contentType: "application/octet-stream", params: []apiParam{
+		queryParam("n", "integer", "Number of bytes, 512 by default."), seedParam}},
+	"/throttle/{size}": {summary: "Streams size bytes at about kbps kilobits per second.", contentType: "application/octet-stream", params: []apiParam{
+		queryParam("kbps", "integer", "Kilobits per second, 128 by default.")}},
+	"/slow/{size}": {summary: "Writes size bytes, sleeping after each.", contentType: "application/octet-stream", params: []apiParam{
+		queryParam("per-byte-ms", "integer", "Milliseconds to sleep after each byte, 10 by default."),
+		queryParam("max-seconds", "integer", "Seconds after which the response is truncated, 30 by default.")}},
+	"/words/{count}":  {summary: "Returns pseudo-random words of text.", contentType: "text/plain", params: []apiParam{seedParam}},
+	"/encoding/mixed": {summary: "Returns text interleaved with invalid UTF-8 sequences.", contentType: "text/plain", params: []apiParam{seedParam}},
+	"/items": {summary: "Returns a page of a synthetic collection with pagination Link headers.", params: []apiParam{
+		queryParam("page", "integer", "Page number, starting at 1."),
+		queryParam("per_page", "integer", "Items per page."),
+		queryParam("total", "integer", "Total number of items.")}},
+	"/struct": {summary: "Returns seeded pseudo-random uint32 values as binary.", contentType: "application/octet-stream", params: []apiParam{
+		queryParam("count", "integer", "Number of values."), seedParam,
+		queryParam("endian", "string", "Byte order of the values, little by default.").withEnum("little", "big")}},
+	"/protobuf":  {summary: "Returns a protocol buffers encoded message with seeded pseudo-random values.", contentType: "application/x-protobuf", params: []apiParam{seedParam}},
+	"/msgpack":   {summary: "Returns the slideshow of /json encoded as MessagePack.", contentType: "application/msgpack"},
+	"/fuzz-json": {summary: "Returns seeded pseudo-random JSON with edge case values.", params: []apiParam{seedParam}},
+	"/delay/random": {summary: "Delays responding for a random duration.", params: []apiParam{
+		queryParam("min", "number", "Minimum delay in seconds."),
+		queryParam("max", "number", "Maximum delay in seconds.")}},
+	"/latency": {summary: "Delays responding for a duration sampled from a distribution, at most DelayMax.", params: []apiParam{
+		queryParam("dist", "string", "Distribution of the delay, normal by default.").withEnum("normal", "exponential", "uniform"),
+		queryParam("mean", "number", "Mean delay in seconds."),
+		queryParam("stddev", "number", "Standard deviation of the normal distribution in seconds."),
+		queryParam("min", "number", "Minimum delay of the uniform distribution in seconds."),
+		queryParam("max", "number", "Maximum delay of the uniform distribution in seconds."),
+		seedParam}},
+	"/delay/{n}": {summary: "Delays responding for min(n, DelayMax) seconds."},
+	"/stream/{n}": {summary: "Streams min(n, StreamMax) lines of JSON objects.", params: []apiParam{
+		queryParam("ramp", "number", "Factor the interval between lines grows to linearly."),
+		queryParam("checksum", "string", "Set to 1 to add the SHA-256 of the previous line to each line.")}},
+	"/stream-trailers/{n}": {summary: "Streams min(n, StreamMax) lines of JSON objects followed by trailers.", params: []apiParam{
+		queryParam("interval", "number", "Seconds between lines, 1 by default.")}},
+	"/task": {summary: "Streams lines of JSON reporting the progress of a simulated task.", contentType: "application/x-ndjson", params: []apiParam{
+		queryParam("duration", "number", "Duration of the task in seconds."),
+		queryParam("interval", "number", "Seconds between progress lines.")}},
+	"/drip": {summary: "Drips data over a duration after an optional delay.", contentType: "application/octet-stream", params: []apiParam{
+		queryParam("delay", "number", "Seconds to wait before dripping."),
+		queryParam("code", "integer", "Status code of the response."),
+		queryParam("offset", "integer", "Bytes to skip without a Range header."),
+		queryParam("trailers", "string", "Set to 1 to end with X-Bytes-Sent and X-Elapsed-Ms trailers.")}},
+	"/cookies": {summary: "Returns the cookies sent."},
+	"/cookies/set": {summary: "Sets the query parameters as cookies and redirects to /cookies.", status: "302", contentType: "-", params: []apiParam{
+		queryParam("cookies", "object", "Cookie names and values.")}},
+	"/cookies/delete": {summary: "Deletes the cookies named in the query and redirects to /cookies.", status: "302", contentType: "-", params: []apiParam{
+		queryParam("cookies", "object", "Names of the cookies to delete.")}},
+	"/cookies/clear": {summary: "Deletes all cookies sent in the request.", status: "302", contentType: "-"},
+	"/cookies/set-many": {summary: "Sets min(count, 1000) cookies.", status: "302", contentType: "-", params: []apiParam{
+		queryParam("count", "integer", "Number of cookies.")}},
+	"/cookies/validate": {summary: "Returns whether each cookie sent conforms to RFC 6265."},
+	"/cache":            {summary: "Returns 304 if an If-Modified-Since or If-None-Match header is sent, 200 otherwise."},
+	"/etag/canonicalize": {summary: "Returns the canonical form of an entity-tag.", params: []apiParam{
+		queryParam("etag", "string", "Entity-tag to canonicalize.")}},
+	"/cache/{n}": {summary: "Sets a Cache-Control header for n seconds."},
+	"/vary": {summary: "Lists the given request headers in Vary and returns their values.", params: []apiParam{
+		queryParam("on", "string", "Comma-separated header names.")}},
+	"/gzip": {summary: "Returns a gzip-encoded response."},
+	"/maybe-gzip": {summary: "Returns gzip-encoded data only if the response is larger than min bytes.", params: []apiParam{
+		queryParam("min", "integer", "Minimum size in bytes to encode.")}},
+	"/brotli": {summary: "Returns a brotli-encoded response.", params: []apiParam{
+		queryParam("quality", "integer", "Encoder quality between 0 and 11, 6 by default."),
+		queryParam("window", "integer", "Base 2 logarithm of the window size between 10 and 24, or 0 for the default.")}},
+	"/deflate":                   {summary: "Returns a deflate-encoded response."},
+	"/html":                      {summary: "Returns some HTML.", contentType: "text/html"},
+	"/json":                      {summary: "Returns the slideshow of /xml as JSON."},
+	"/xml":                       {summary: "Returns some XML.", contentType: "text/xml"},
+	"/yaml":                      {summary: "Returns the slideshow of /xml as YAML.", contentType: "application/yaml"},
+	"/robots.txt":                {summary: "Returns some robots.txt rules.", contentType: "text/plain"},
+	"/deny":                      {summary: "Returns a page denied by robots.txt.", contentType: "text/plain"},
+	"/malformed-json":            {summary: "Returns an intentionally truncated JSON body."},
+	"/basic-auth/{u}/{p}":        {summary: "Challenges HTTP Basic Auth."},
+	"/hidden-basic-auth/{u}/{p}": {summary: "Challenges HTTP Basic Auth and returns 404 on failure."},
+	"/bearer":                    {summary: "Challenges Bearer token auth, accepting any token."},
+	"/auth/echo":                 {summary: "Returns the scheme and credentials of the Authorization header."},
+	"/accept/parse":              {summary: "Returns the media ranges of the Accept header sorted by preference."},
+	"/image/gif":                 {summary: "Returns an animated GIF image.", contentType: "image/gif", params: []apiParam{saveDataParam}},
+	"/image/png":                 {summary: "Returns a PNG image.", contentType: "image/png", params: []apiParam{saveDataParam}},
+	"/image/jpeg":                {summary: "Returns a JPEG image.", contentType: "image/jpeg", params: []apiParam{saveDataParam}},
+	"/image/text": {summary: "Returns a PNG image with the given text drawn across it.", contentType: "image/png", params: []apiParam{
+		queryParam("content", "string", "Text to draw, at most 64 characters."),
+		queryParam("seed", "integer", "Seed of the position and color of the text.").withFormat("int64")}},
+	"/sse/heartbeat": {summary: "Sends server-sent event heartbeat comments, then a data event.", contentType: "text/event-stream", params: []apiParam{
+		queryParam("interval", "number", "Seconds between heartbeats."),
+		queryParam("count", "integer", "Number of heartbeats.")}},
+	"/multipart": {summary: "Returns a multipart/mixed response.", contentType: "multipart/mixed", params: []apiParam{
+		queryParam("parts", "integer", "Number of parts.")}},
+	"/poll": {summary: "Long-polls until an event is triggered with POST /poll/trigger.", params: []apiParam{
+		queryParam("wait", "number", "Seconds to wait at most."),
+		queryParam("random", "string", "Set to 1 to simulate an event at a random time.")}},
+	"/poll/trigger": {summary: "Triggers an event for the pending /poll requests."},
+	"/growing": {summary: "Returns a resource that grows on each GET in the same session.", contentType: "text/plain", params: []apiParam{
+		queryParam("step", "integer", "Bytes to grow by, 100 by default."),
+		queryParam("session", "string", "Session to use instead of the cookie.")}},
+	"/growing/reset": {summary: "Starts the /growing session over.", status: "204", contentType: "-", params: []apiParam{
+		queryParam("session", "string", "Session to use instead of the cookie.")}},
+	"/digest-auth/{qop}/{u}/{p}": {summary: "Challenges HTTP Digest Auth with MD5."},
+	"/resources":                 {summary: "Stores the JSON body and returns the created resource.", status: "201"},
+	"/resources/{id}":            {summary: "Returns a resource created with POST /resources."},
+	"/requests":                  {summary: "Returns the recorded requests."},
+	"/requests/clear":            {summary: "Clears the recorded requests.", status: "204", contentType: "-"},
+	"/debug/stats":               {summary: "Returns runtime statistics."},
+	"/greeting":                  {summary: "Returns a greeting in the language negotiated with Accept-Language."},
+	"/spec":                      {summary: "Returns the path templates and methods of all endpoints."},
+	"/openapi.json":              {summary: "Returns this document."},
+}
+
+// routeVar matches the variables of mux path templates, whose patterns may
+// hold braces of their own.
+var routeVar = regexp.MustCompile(`\{(\w+)(?::((?:[^{}]|\{[^{}]*\})*))?\}`)
+
+// openAPIPath returns the path template tpl without the patterns of its
+// variables.
+func openAPIPath(tpl string) string {
+	return routeVar.ReplaceAllString(tpl, "{$1}")
+}
+
+// openAPISchemaOf returns the schema of values matching the route variable
+// pattern.
+func openAPISchemaOf(pattern string) openAPISchema {
+	switch {
+	case pattern == `[\d]+` || pattern == `\d+`:
+		return openAPISchema{Type: "integer"}
+	case strings.HasPrefix(pattern, `\d+(?:\.`):
+		return openAPISchema{Type: "number"}
+	case regexp.MustCompile(`^[\w-]+(\|[\w-]+)+$`).MatchString(pattern):
+		return openAPISchema{Type: "string", Enum: strings.Split(pattern, "|")}
+	}
+	return openAPISchema{Type: "string"}
+}
+
+// openAPIHandler returns a handler serving an OpenAPI 3 document of the
+// routes registered on router, described by apiPaths.
+func openAPIHandler(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc := openAPIDocument{
+			OpenAPI: "3.0.3",
+			Info: openAPIInfo{
+				Title:       "go-httpbin",
+				Description: "HTTP request and response testing endpoints.",
+				Version:     "1.0.0",
+				License:     openAPILicense{Name: "Apache 2.0", URL: "http://www.apache.org/licenses/LICENSE-2.0"},
+			},
+			Paths: make(map[string]map[string]openAPIOperation),
+		}
+		err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			tpl, err := route.GetPathTemplate()
+			if err != nil {
+				return nil // not a path route
+			}
+			methods, err := route.GetMethods()
+			if err != nil { // any method
+				methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+			}
+			queries, _ := route.GetQueriesTemplates()
+
+			path := openAPIPath(tpl)
+			meta := apiPaths[path]
+			var params []openAPIParameter
+			for _, m := range routeVar.FindAllStringSubmatch(tpl, -1) {
+				params = append(params, openAPIParameter{Name: m[1], In: "path", Required: true, Schema: openAPISchemaOf(m[2])})
+			}
+			for _, q := range queries {
+				kv := strings.SplitN(q, "=", 2)
+				var pattern string
+				if m := routeVar.FindStringSubmatch(kv[len(kv)-1]); m != nil {
+					pattern = m[2]
+				}
+				params = append(params, openAPIParameter{Name: kv[0], In: "query", Required: true, Schema: openAPISchemaOf(pattern)})
+			}
+			for _, p := range meta.params {
+				v := openAPIParameter{Name: p.name, In: p.in, Description: p.description,
+					Schema: openAPISchema{Type: p.typ, Format: p.format, Enum: p.enum}}
+				if p.typ == "object" {
+					v.Style, v.Explode = "form", true
+					v.Schema.AdditionalProperties = &openAPISchema{Type: "string"}
+				}
+				params = append(params, v)
+			}
+
+			status, resp := meta.status, openAPIResponse{Description: "Response."}
+			if status == "" {
+				status = "200"
+			}
+			switch ct := meta.contentType; ct {
+			case "-":
+			case "", "application/json":
+				resp.Content = map[string]openAPIMediaType{"application/json": {Schema: openAPISchema{Type: "object"}}}
+			default:
+				resp.Content = map[string]openAPIMediaType{ct: {Schema: openAPISchema{Type: "string", Format: "binary"}}}
+			}
+
+			ops := doc.Paths[path]
+			if ops == nil {
+				ops = make(map[string]openAPIOperation)
+				doc.Paths[path] = ops
+			}
+			for _, m := range methods {
+				if m == http.MethodHead {
+					continue
+				}
+				op := openAPIOperation{
+					Summary:     meta.summary,
+					OperationID: strings.ToLower(m) + " " + path,
+					Parameters:  params,
+					Responses:   map[string]openAPIResponse{status: resp},
+				}
+				switch m {
+				case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+					op.RequestBody = &openAPIRequestBody{Content: map[string]openAPIMediaType{
+						"*/*": {Schema: openAPISchema{Type: "string", Format: "binary"}},
+					}}
+				}
+				ops[strings.ToLower(m)] = op
+			}
+			return nil
+		})
+		if err != nil {
+			writeErrorJSON(w, errors.Wrap(err, "failed to list routes"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := writeJSON(w, doc); err != nil {
+			writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+		}
+	}
+}
+
+// undocumentedPaths returns the paths of the routes of router missing from
+// apiPaths, and the paths of apiPaths without a route, sorted.
+func undocumentedPaths(router *mux.Router) (missing, stale []string) {
+	seen := make(map[string]bool)
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			seen[openAPIPath(tpl)] = true
+		}
+		return nil
+	})
+	for p := range seen {
+		if _, ok := apiPaths[p]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	for p := range apiPaths {
+		if !seen[p] {
+			stale = append(stale, p)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	return missing, stale
+}
@@ -0,0 +1,13 @@
+package httpbin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIPaths(t *testing.T) {
+	missing, stale := undocumentedPaths(GetMux(WithRequestRecording(10), WithDebugStats()))
+	require.Empty(t, missing, "routes missing from apiPaths")
+	require.Empty(t, stale, "apiPaths without a route")
+}
@@ -0,0 +1,118 @@
+package httpbin
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures the mux returned by GetMux.
+type Option func(*options)
+
+type options struct {
+	defaultHeaders http.Header
+	responseTime   bool
+	compression    bool
+	jsonKeyStyle   JSONKeyStyle
+	debugStats     bool
+	delayLimit     int
+	resourceTTL    time.Duration
+	recordMax      int
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{resourceTTL: ResourceTTL}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithDefaultHeaders sets headers to be added to every response. Headers set
+// by a handler take precedence over the defaults.
+func WithDefaultHeaders(h http.Header) Option {
+	return func(o *options) {
+		if o.defaultHeaders == nil {
+			o.defaultHeaders = make(http.Header, len(h))
+		}
+		for k, v := range h {
+			o.defaultHeaders[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+}
+
+// WithResponseTime adds an X-Response-Time header to every response reporting
+// the milliseconds spent in the handler before the response headers were
+// written.
+func WithResponseTime() Option {
+	return func(o *options) {
+		o.responseTime = true
+	}
+}
+
+// WithCompression gzip-encodes responses for clients that accept it.
+// Endpoints that produce their own encoding are left untouched, and streaming
+// endpoints such as /drip and /stream are never compressed so that their
+// timing is preserved.
+func WithCompression() Option {
+	return func(o *options) {
+		o.compression = true
+	}
+}
+
+// JSONKeyStyle determines how the keys of JSON response objects are spelled.
+// Keys that echo request data, such as header names or query arguments, are
+// never changed.
+type JSONKeyStyle int
+
+const (
+	// JSONKeyDefault spells keys like httpbin.org does, e.g. "user-agent".
+	JSONKeyDefault JSONKeyStyle = iota
+	// JSONKeySnake spells keys in snake_case, e.g. "user_agent".
+	JSONKeySnake
+	// JSONKeyCamel spells keys in camelCase, e.g. "userAgent".
+	JSONKeyCamel
+)
+
+// WithJSONKeyStyle sets how the keys of JSON response objects are spelled.
+func WithJSONKeyStyle(style JSONKeyStyle) Option {
+	return func(o *options) {
+		o.jsonKeyStyle = style
+	}
+}
+
+// WithDebugStats enables /debug/stats, which reports the number of goroutines
+// and memory statistics of the process. It is disabled by default as it
+// exposes details of the server itself.
+func WithDebugStats() Option {
+	return func(o *options) {
+		o.debugStats = true
+	}
+}
+
+// WithDelayConcurrencyLimit makes /delay respond with 503 Service Unavailable
+// right away while n of its requests are already in flight, to simulate an
+// overloaded backend. Zero means no limit.
+func WithDelayConcurrencyLimit(n int) Option {
+	return func(o *options) {
+		o.delayLimit = n
+	}
+}
+
+// WithResourceTTL makes the resources created through /resources expire d
+// after their creation, instead of after ResourceTTL. With d of zero they are
+// kept until evicted to make room for newer ones.
+func WithResourceTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.resourceTTL = d
+	}
+}
+
+// WithRequestRecording records the last n requests served by the mux, with
+// their headers and the start of their body, for /requests to return and
+// /requests/clear to forget. It is disabled by default as it exposes the
+// requests of every client to every other.
+func WithRequestRecording(n int) Option {
+	return func(o *options) {
+		o.recordMax = n
+	}
+}
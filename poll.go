@@ -0,0 +1,95 @@
+package httpbin
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// poller notifies long-polling requests of events triggered by other
+// requests.
+type poller struct {
+	mu    sync.Mutex
+	seq   int
+	event chan struct{} // closed when the next event fires
+}
+
+func newPoller() *poller {
+	return &poller{event: make(chan struct{})}
+}
+
+// trigger fires an event, waking up all pending polls.
+func (p *poller) trigger() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seq++
+	close(p.event)
+	p.event = make(chan struct{})
+	return p.seq
+}
+
+func (p *poller) next() (<-chan struct{}, func() int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.event, func() int {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.seq
+	}
+}
+
+// pollHandler blocks for up to 'wait' seconds (default 5, clamped to
+// DelayMax) until an event is triggered through /poll/trigger, then returns
+// the event. It returns 204 No Content if no event fires in time. With
+// 'random=1' an event is simulated at a random time within the wait.
+func (p *poller) pollHandler(w http.ResponseWriter, r *http.Request) {
+	wait := 5 * time.Second
+	if v := r.URL.Query().Get("wait"); v != "" {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 {
+			writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("failed to parse 'wait'"))
+			return
+		}
+		wait = time.Millisecond * time.Duration(n*float64(time.Second/time.Millisecond))
+	}
+	if wait > DelayMax {
+		wait = DelayMax
+	}
+
+	event, seq := p.next()
+	var simulated <-chan time.Time
+	if r.URL.Query().Get("random") == "1" && wait > 0 {
+		simulated = time.After(time.Duration(rand.Int63n(int64(wait))))
+	}
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	var v pollResponse
+	select {
+	case <-event:
+		v = pollResponse{Event: seq(), Source: "trigger"}
+	case <-simulated:
+		v = pollResponse{Event: seq(), Source: "random"}
+	case <-timeout.C:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case <-r.Context().Done():
+		return // client went away
+	}
+	v.Time = time.Now().UTC()
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// triggerHandler fires an event, completing all pending polls.
+func (p *poller) triggerHandler(w http.ResponseWriter, r *http.Request) {
+	v := pollResponse{Event: p.trigger(), Source: "trigger", Time: time.Now().UTC()}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
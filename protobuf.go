@@ -0,0 +1,74 @@
+package httpbin
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// protoItem is served by /protobuf, encoded as the following message:
+//
+//	message Item {
+//	  int64 id = 1;
+//	  string name = 2;
+//	  repeated uint32 values = 3; // packed
+//	  bool active = 4;
+//	  double score = 5;
+//	}
+type protoItem struct {
+	ID     int64
+	Name   string
+	Values []uint32
+	Active bool
+	Score  float64
+}
+
+// Protocol buffers wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// marshal encodes the item in the protocol buffers wire format. Fields with
+// default values are omitted, as proto3 encoders do.
+func (m protoItem) marshal() []byte {
+	var b []byte
+	if m.ID != 0 {
+		b = appendProtoTag(b, 1, protoVarint)
+		b = appendUvarint(b, uint64(m.ID))
+	}
+	if m.Name != "" {
+		b = appendProtoTag(b, 2, protoBytes)
+		b = appendUvarint(b, uint64(len(m.Name)))
+		b = append(b, m.Name...)
+	}
+	if len(m.Values) > 0 {
+		var packed []byte
+		for _, v := range m.Values {
+			packed = appendUvarint(packed, uint64(v))
+		}
+		b = appendProtoTag(b, 3, protoBytes)
+		b = appendUvarint(b, uint64(len(packed)))
+		b = append(b, packed...)
+	}
+	if m.Active {
+		b = appendProtoTag(b, 4, protoVarint)
+		b = append(b, 1)
+	}
+	if m.Score != 0 {
+		b = appendProtoTag(b, 5, protoFixed64)
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(m.Score))
+		b = append(b, buf[:]...)
+	}
+	return b
+}
+
+func appendProtoTag(b []byte, field, wireType int) []byte {
+	return appendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
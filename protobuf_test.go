@@ -0,0 +1,26 @@
+package httpbin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtoItemMarshal(t *testing.T) {
+	b := protoItem{
+		ID:     150,
+		Name:   "ab",
+		Values: []uint32{3, 270},
+		Active: true,
+		Score:  1,
+	}.marshal()
+	require.Equal(t, []byte{
+		0x08, 0x96, 0x01, // id = 150
+		0x12, 0x02, 'a', 'b', // name = "ab"
+		0x1a, 0x03, 0x03, 0x8e, 0x02, // values = [3, 270]
+		0x20, 0x01, // active = true
+		0x29, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, // score = 1.0
+	}, b)
+
+	require.Empty(t, protoItem{}.marshal())
+}
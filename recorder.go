@@ -0,0 +1,123 @@
+package httpbin
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// recordBodyMax is the maximum number of body bytes kept for each recorded
+// request.
+const recordBodyMax = 1024
+
+// requestRecorder keeps the last requests served by the mux in a ring buffer
+// for /requests.
+type requestRecorder struct {
+	mu    sync.Mutex
+	buf   []recordedRequest
+	next  int // index in buf the next request is recorded at
+	count int // number of requests in buf
+}
+
+func newRequestRecorder(n int) *requestRecorder {
+	return &requestRecorder{buf: make([]recordedRequest, n)}
+}
+
+func (rec *requestRecorder) add(v recordedRequest) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.buf[rec.next] = v
+	rec.next = (rec.next + 1) % len(rec.buf)
+	if rec.count < len(rec.buf) {
+		rec.count++
+	}
+}
+
+// requests returns the recorded requests, oldest first.
+func (rec *requestRecorder) requests() []recordedRequest {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	out := make([]recordedRequest, 0, rec.count)
+	start := (rec.next - rec.count + len(rec.buf)) % len(rec.buf)
+	for i := 0; i < rec.count; i++ {
+		out = append(out, rec.buf[(start+i)%len(rec.buf)])
+	}
+	return out
+}
+
+func (rec *requestRecorder) clear() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for i := range rec.buf {
+		rec.buf[i] = recordedRequest{} // let the recorded headers and bodies be collected
+	}
+	rec.next, rec.count = 0, 0
+}
+
+// middleware records each request once its handler returns, so that the body
+// snippet includes what the handler read. Requests to /requests itself are
+// not recorded.
+func (rec *requestRecorder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/requests" || r.URL.Path == "/requests/clear" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		v := recordedRequest{
+			Time:    time.Now().UTC(),
+			Method:  r.Method,
+			URL:     r.URL.RequestURI(),
+			Headers: r.Header.Clone(),
+		}
+		body := &snippetReader{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+		next.ServeHTTP(w, r)
+
+		// top up the snippet if the handler did not read the whole body,
+		// which fails harmlessly if the server already discarded it
+		if r.Body == body && r.ContentLength > 0 && !body.truncated {
+			io.Copy(ioutil.Discard, io.LimitReader(body, recordBodyMax+1))
+		}
+		v.Body = string(body.snippet)
+		v.BodyTruncated = body.truncated
+		rec.add(v)
+	})
+}
+
+// snippetReader keeps the first recordBodyMax bytes read from the wrapped
+// body.
+type snippetReader struct {
+	io.ReadCloser
+	snippet   []byte
+	truncated bool
+}
+
+func (s *snippetReader) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	if room := recordBodyMax - len(s.snippet); n > room {
+		s.snippet = append(s.snippet, p[:room]...)
+		s.truncated = true
+	} else {
+		s.snippet = append(s.snippet, p[:n]...)
+	}
+	return n, err
+}
+
+// listHandler returns the recorded requests, oldest first.
+func (rec *requestRecorder) listHandler(w http.ResponseWriter, r *http.Request) {
+	if err := writeJSON(w, recordedRequestsResponse{Requests: rec.requests()}); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// clearHandler forgets the recorded requests.
+func (rec *requestRecorder) clearHandler(w http.ResponseWriter, r *http.Request) {
+	rec.clear()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,110 @@
+package httpbin
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// resourceStore keeps the resources created through /resources in memory.
+// Once ResourcesMax resources or ResourcesBytesMax bytes of their data are
+// kept, the oldest ones are evicted to make room for new ones.
+type resourceStore struct {
+	ttl time.Duration // zero means resources never expire
+
+	mu        sync.Mutex
+	lastID    int
+	resources map[string]*list.Element
+	order     *list.List // resourceResponse values, oldest first
+	size      int        // total bytes of resource data
+}
+
+func newResourceStore(ttl time.Duration) *resourceStore {
+	return &resourceStore{ttl: ttl, resources: make(map[string]*list.Element), order: list.New()}
+}
+
+// expired reports whether v has outlived the TTL of the store at now.
+func (s *resourceStore) expired(v resourceResponse, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(v.Created) >= s.ttl
+}
+
+// remove forgets the resource in e. s.mu must be held.
+func (s *resourceStore) remove(e *list.Element) {
+	v := s.order.Remove(e).(resourceResponse)
+	delete(s.resources, v.ID)
+	s.size -= len(v.Data)
+}
+
+// createHandler stores the JSON request body as a new resource, responding
+// with 201 Created, the resource and its URL in the Location header. Bodies
+// larger than ResourcesBytesMax are rejected with 413.
+func (s *resourceStore) createHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := parseData(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if len(body) > ResourcesBytesMax {
+		writeBodyError(w, errBodyTooLarge)
+		return
+	}
+	if !json.Valid(body) {
+		writeErrorJSONStatus(w, http.StatusBadRequest, errors.New("body is not valid JSON"))
+		return
+	}
+
+	now := time.Now().UTC()
+	s.mu.Lock()
+	// resources expire in creation order, so only the oldest ones need to
+	// be looked at
+	for e := s.order.Front(); e != nil; e = s.order.Front() {
+		if !s.expired(e.Value.(resourceResponse), now) && s.order.Len() < ResourcesMax &&
+			s.size+len(body) <= ResourcesBytesMax {
+			break
+		}
+		s.remove(e)
+	}
+	s.lastID++
+	v := resourceResponse{ID: strconv.Itoa(s.lastID), Created: now, Data: json.RawMessage(body)}
+	s.resources[v.ID] = s.order.PushBack(v)
+	s.size += len(body)
+	s.mu.Unlock()
+
+	w.Header().Set("Location", "/resources/"+v.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
+
+// getHandler returns the resource with the id in the path, or 404 if there is
+// no such resource or it expired.
+func (s *resourceStore) getHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var v resourceResponse
+	s.mu.Lock()
+	e, ok := s.resources[id]
+	if ok {
+		v = e.Value.(resourceResponse)
+		if s.expired(v, time.Now()) {
+			s.remove(e)
+			ok = false
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeErrorJSONStatus(w, http.StatusNotFound, errors.Errorf("resource %q not found", id))
+		return
+	}
+	if err := writeJSON(w, v); err != nil {
+		writeErrorJSON(w, errors.Wrap(err, "failed to write json"))
+	}
+}
@@ -1,9 +1,19 @@
 package httpbin
 
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
 type ipResponse struct {
 	Origin string `json:"origin"`
 }
 
+type hostnameResponse struct {
+	Hostname string `json:"hostname"`
+}
+
 type errorResponse struct {
 	Error errObj `json:"error"`
 }
@@ -12,34 +22,226 @@ type errObj struct {
 	Message string `json:"message"`
 }
 
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type robotsResponse struct {
+	UserAgent string   `json:"user_agent"`
+	Disallow  []string `json:"disallow"`
+}
+
 type userAgentResponse struct {
 	UA string `json:"user-agent"`
 }
 
+type userAgentParseResponse struct {
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browser_version"`
+	OS             string `json:"os"`
+	Device         string `json:"device"`
+	Bot            bool   `json:"bot"`
+	Raw            string `json:"raw"`
+}
+
+type httpVersionResponse struct {
+	Proto      string `json:"proto"`
+	ProtoMajor int    `json:"proto_major"`
+	ProtoMinor int    `json:"proto_minor"`
+}
+
+type h2InfoResponse struct {
+	Proto         string `json:"proto"`
+	HTTP2         bool   `json:"http2"`
+	PushSupported bool   `json:"push_supported"`
+}
+
+type alpnResponse struct {
+	TLS                bool   `json:"tls"`
+	NegotiatedProtocol string `json:"negotiated_protocol"`
+	Proto              string `json:"proto"`
+	Note               string `json:"note,omitempty"`
+}
+
+type limitsResponse struct {
+	MaxBodySize    int64   `json:"max_body_size"`
+	MaxBytes       int     `json:"max_bytes"`
+	MaxDelay       float64 `json:"max_delay"`
+	MaxStreamCount int     `json:"max_stream_count"`
+}
+
+type debugStatsResponse struct {
+	Goroutines  int    `json:"goroutines"`
+	Alloc       uint64 `json:"alloc"`
+	TotalAlloc  uint64 `json:"total_alloc"`
+	Sys         uint64 `json:"sys"`
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapInuse   uint64 `json:"heap_inuse"`
+	HeapObjects uint64 `json:"heap_objects"`
+	NumGC       uint32 `json:"num_gc"`
+}
+
+type specResponse struct {
+	Routes []specRoute `json:"routes"`
+}
+
+type specRoute struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+	Queries []string `json:"queries,omitempty"`
+}
+
 type headersResponse struct {
 	Headers map[string]string `json:"headers"`
 }
 
+type hopByHopResponse struct {
+	Present  bool              `json:"present"`
+	HopByHop map[string]string `json:"hop_by_hop"`
+	Headers  map[string]string `json:"headers,omitempty"`
+}
+
+type methodResponse struct {
+	Method          string `json:"method"`
+	EffectiveMethod string `json:"effective_method"`
+	OverrideSource  string `json:"override_source,omitempty"`
+}
+
+type geoResponse struct {
+	Country   string   `json:"country"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Source    string   `json:"source,omitempty"`
+}
+
+type selfResponse struct {
+	BaseURL string `json:"base_url"`
+	URL     string `json:"url"`
+	Path    string `json:"path"`
+}
+
+type resolveResponse struct {
+	Target  string `json:"target"`
+	Decoded string `json:"decoded"`
+	Cleaned string `json:"cleaned"`
+	Changed bool   `json:"changed"`
+}
+
+type schemeResponse struct {
+	Scheme          string `json:"scheme"`
+	TLS             string `json:"tls"`
+	XForwardedProto string `json:"x_forwarded_proto,omitempty"`
+	Forwarded       string `json:"forwarded,omitempty"`
+	Consistent      bool   `json:"consistent"`
+}
+
+type requestLineResponse struct {
+	Method      string `json:"method"`
+	RequestURI  string `json:"request_uri"`
+	Proto       string `json:"proto"`
+	RequestLine string `json:"request_line"`
+}
+
+type timingResponse struct {
+	ReceivedAt  time.Time `json:"received_at"`
+	RespondedAt time.Time `json:"responded_at"`
+	BodyBytes   int64     `json:"body_bytes"`
+	BodyReadMs  float64   `json:"body_read_ms"`
+	HandlerMs   float64   `json:"handler_ms"`
+}
+
+type proxyEchoResponse struct {
+	RequestURI   string `json:"request_uri"`
+	URL          string `json:"url"`
+	Scheme       string `json:"scheme"`
+	Host         string `json:"host"`
+	Path         string `json:"path"`
+	RawQuery     string `json:"raw_query"`
+	HostHeader   string `json:"host_header"`
+	AbsoluteForm bool   `json:"absolute_form"`
+}
+
+type redirectPostResponse struct {
+	Method   string `json:"method"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
+}
+
 type cookiesResponse struct {
 	Cookies map[string]string `json:"cookies"`
 }
 
+type validateCookiesResponse struct {
+	Valid   bool             `json:"valid"`
+	Cookies []cookieValidity `json:"cookies"`
+}
+
+type cookieValidity struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Valid    bool   `json:"valid"`
+	Error    string `json:"error,omitempty"`
+	Accepted bool   `json:"accepted"`
+}
+
 type getResponse struct {
 	headersResponse
 	ipResponse
-	URL  string                 `json:"url"`
-	Args map[string]interface{} `json:"args"`
+	URL     string                 `json:"url"`
+	Args    map[string]interface{} `json:"args"`
+	Proxied *bool                  `json:"proxied,omitempty"`
+	Via     []string               `json:"via,omitempty"`
+}
+
+type etagResponse struct {
+	Input      string `json:"input"`
+	ETag       string `json:"etag"`
+	Weak       bool   `json:"weak"`
+	Opaque     string `json:"opaque"`
+	Comparison string `json:"comparison"`
+	Canonical  bool   `json:"canonical"`
+}
+
+type anythingResponse struct {
+	headersResponse
+	ipResponse
+	Method string                 `json:"method"`
+	URL    string                 `json:"url"`
+	Args   map[string]interface{} `json:"args"`
+	Data   string                 `json:"data"`
+	Files  map[string]string      `json:"files"`
+	Form   map[string]interface{} `json:"form"`
+	JSON   interface{}            `json:"json"`
 }
 
 type postResponse struct {
 	headersResponse
 	ipResponse
-	URL   string                 `json:"url"`
-	Args  map[string]interface{} `json:"args"`
-	Data  string                 `json:"data"`
-	Files map[string]string      `json:"files"`
-	Form  map[string]interface{} `json:"form"`
-	JSON  interface{}            `json:"json"`
+	URL    string                 `json:"url"`
+	Args   map[string]interface{} `json:"args"`
+	Data   string                 `json:"data"`
+	Files  map[string]string      `json:"files"`
+	Form   map[string]interface{} `json:"form"`
+	JSON   interface{}            `json:"json"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+type postStreamRecord struct {
+	Index  int             `json:"index"`
+	Record json.RawMessage `json:"record"`
+}
+
+type streamUploadAck struct {
+	Lines   int    `json:"lines"`
+	Invalid int    `json:"invalid"`
+	Done    bool   `json:"done"`
+	Error   string `json:"error,omitempty"`
+}
+
+type varyResponse struct {
+	Vary    []string          `json:"vary"`
+	Headers map[string]string `json:"headers"`
+	Variant string            `json:"variant"`
 }
 
 type gzipResponse struct {
@@ -48,6 +250,11 @@ type gzipResponse struct {
 	Gzipped bool `json:"gzipped"`
 }
 
+type maybeGzipResponse struct {
+	getResponse
+	Gzipped bool `json:"gzipped"`
+}
+
 type deflateResponse struct {
 	headersResponse
 	ipResponse
@@ -58,9 +265,202 @@ type brotliResponse struct {
 	headersResponse
 	ipResponse
 	Compressed bool `json:"compressed"`
+	Quality    int  `json:"quality"`
+	Window     int  `json:"window"`
+}
+
+type authEchoResponse struct {
+	Present     bool    `json:"present"`
+	Scheme      string  `json:"scheme,omitempty"`
+	Credentials string  `json:"credentials,omitempty"`
+	User        *string `json:"user,omitempty"`
+	Password    *string `json:"password,omitempty"`
+	ValidFormat *bool   `json:"valid_format,omitempty"`
+}
+
+type acceptEntry struct {
+	Type       string            `json:"type"`
+	Subtype    string            `json:"subtype"`
+	Q          float64           `json:"q"`
+	Params     map[string]string `json:"params,omitempty"`
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+type acceptParseResponse struct {
+	Accept  string        `json:"accept"`
+	Entries []acceptEntry `json:"entries"`
+}
+
+type bearerResponse struct {
+	Authenticated bool   `json:"authenticated"`
+	Token         string `json:"token"`
+	ValidFormat   bool   `json:"valid_format"`
 }
 
 type basicAuthResponse struct {
 	Authenticated bool   `json:"authenticated"`
 	User          string `json:"user"`
 }
+
+type greetingResponse struct {
+	Language string `json:"language"`
+	Greeting string `json:"greeting"`
+}
+
+type keepAliveResponse struct {
+	ReadTimeout       float64 `json:"read_timeout"`
+	ReadHeaderTimeout float64 `json:"read_header_timeout"`
+	WriteTimeout      float64 `json:"write_timeout"`
+	IdleTimeout       float64 `json:"idle_timeout"`
+}
+
+type connCountResponse struct {
+	Count int64 `json:"count"`
+}
+
+type itemsResponse struct {
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+	Total   int    `json:"total"`
+	Items   []item `json:"items"`
+	Next    string `json:"next,omitempty"`
+	Prev    string `json:"prev,omitempty"`
+}
+
+type item struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type verifyHMACResponse struct {
+	Valid    bool   `json:"valid"`
+	Expected string `json:"expected"`
+}
+
+type verifyLengthResponse struct {
+	Declared int64  `json:"declared"`
+	Received int64  `json:"received"`
+	Match    bool   `json:"match"`
+	Error    string `json:"error,omitempty"`
+}
+
+type uploadTrailerResponse struct {
+	Valid    bool   `json:"valid"`
+	Expected string `json:"expected"`
+	Trailer  string `json:"trailer"`
+}
+
+type dupHeader struct {
+	Count     int      `json:"count"`
+	Values    []string `json:"values"`
+	RawCount  *int     `json:"raw_count,omitempty"`
+	RawValues []string `json:"raw_values,omitempty"`
+}
+
+type dupHeadersResponse struct {
+	Headers map[string]dupHeader `json:"headers"`
+	Note    string               `json:"note,omitempty"`
+}
+
+type orderedHeadersResponse struct {
+	Headers [][2]string `json:"headers"`
+}
+
+type recordedRequest struct {
+	Time          time.Time   `json:"time"`
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Headers       http.Header `json:"headers"`
+	Body          string      `json:"body"`
+	BodyTruncated bool        `json:"body_truncated"`
+}
+
+type recordedRequestsResponse struct {
+	Requests []recordedRequest `json:"requests"`
+}
+
+type resourceResponse struct {
+	ID      string          `json:"id"`
+	Created time.Time       `json:"created"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type pollResponse struct {
+	Event  int       `json:"event"`
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+}
+
+type slideshowResponse struct {
+	Slideshow slideshow `json:"slideshow"`
+}
+
+type slideshow struct {
+	Title  string  `json:"title"`
+	Date   string  `json:"date"`
+	Author string  `json:"author"`
+	Slides []slide `json:"slides"`
+}
+
+type slide struct {
+	Type  string   `json:"type"`
+	Title string   `json:"title"`
+	Items []string `json:"items,omitempty"`
+}
+
+// OpenAPI 3 document of /openapi.json, only with the fields used.
+type openAPIDocument struct {
+	OpenAPI string                                 `json:"openapi"`
+	Info    openAPIInfo                            `json:"info"`
+	Paths   map[string]map[string]openAPIOperation `json:"paths"`
+}
+
+type openAPIInfo struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Version     string         `json:"version"`
+	License     openAPILicense `json:"license"`
+}
+
+type openAPILicense struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary"`
+	OperationID string                     `json:"operationId"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Style       string        `json:"style,omitempty"`
+	Explode     bool          `json:"explode,omitempty"`
+	Schema      openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Type                 string         `json:"type"`
+	Format               string         `json:"format,omitempty"`
+	Enum                 []string       `json:"enum,omitempty"`
+	AdditionalProperties *openAPISchema `json:"additionalProperties,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Content map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema openAPISchema `json:"schema"`
+}
@@ -0,0 +1,82 @@
+package httpbin
+
+import "strings"
+
+// uaBrowsers maps product tokens to browser names, in the order they are
+// matched. Browsers based on others also send the tokens of their bases
+// (e.g. Edge sends "Chrome/" and "Safari/"), so they are matched first.
+var uaBrowsers = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"FxiOS/", "Firefox"},
+	{"Firefox/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Version/", "Safari"}, // Safari reports its version in "Version/"
+	{"MSIE ", "Internet Explorer"},
+	{"Trident/", "Internet Explorer"},
+	{"curl/", "curl"},
+	{"Wget/", "Wget"},
+	{"Go-http-client/", "Go-http-client"},
+	{"python-requests/", "python-requests"},
+}
+
+// uaOSes maps substrings to operating system names, in the order they are
+// matched.
+var uaOSes = []struct{ token, name string }{
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"Android", "Android"},
+	{"CrOS", "ChromeOS"},
+	{"Mac OS X", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// uaBotTokens are lowercase substrings identifying crawlers.
+var uaBotTokens = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "headless"}
+
+// parseUserAgent extracts the browser, operating system and device type from
+// a User-Agent header value with a small set of rules. Fields that cannot be
+// determined are left empty.
+func parseUserAgent(ua string) userAgentParseResponse {
+	v := userAgentParseResponse{Raw: ua}
+	for _, b := range uaBrowsers {
+		if i := strings.Index(ua, b.token); i >= 0 {
+			v.Browser = b.name
+			v.BrowserVersion = ua[i+len(b.token):]
+			if j := strings.IndexAny(v.BrowserVersion, " ;)"); j >= 0 {
+				v.BrowserVersion = v.BrowserVersion[:j]
+			}
+			break
+		}
+	}
+	for _, o := range uaOSes {
+		if strings.Contains(ua, o.token) {
+			v.OS = o.name
+			break
+		}
+	}
+
+	lower := strings.ToLower(ua)
+	for _, t := range uaBotTokens {
+		if strings.Contains(lower, t) {
+			v.Bot = true
+			break
+		}
+	}
+	switch {
+	case v.Bot:
+		v.Device = "bot"
+	case strings.Contains(ua, "iPad") || strings.Contains(ua, "Tablet"):
+		v.Device = "tablet"
+	case strings.Contains(ua, "Mobile") || strings.Contains(ua, "iPhone"):
+		v.Device = "mobile"
+	case v.OS == "Android":
+		v.Device = "tablet" // Android tablets omit "Mobile"
+	case v.OS != "":
+		v.Device = "desktop"
+	}
+	return v
+}
@@ -0,0 +1,36 @@
+package httpbin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	for _, tc := range []struct {
+		ua                           string
+		browser, version, os, device string
+		bot                          bool
+	}{
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			"Chrome", "120.0.0.0", "macOS", "desktop", false},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			"Edge", "120.0.2210.91", "Windows", "desktop", false},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			"Safari", "17.2", "iOS", "mobile", false},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			"Firefox", "121.0", "Linux", "desktop", false},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			"", "", "", "bot", true},
+		{"curl/8.4.0", "curl", "8.4.0", "", "", false},
+		{"something-unknown", "", "", "", "", false},
+	} {
+		v := parseUserAgent(tc.ua)
+		require.Equal(t, tc.browser, v.Browser, tc.ua)
+		require.Equal(t, tc.version, v.BrowserVersion, tc.ua)
+		require.Equal(t, tc.os, v.OS, tc.ua)
+		require.Equal(t, tc.device, v.Device, tc.ua)
+		require.Equal(t, tc.bot, v.Bot, tc.ua)
+		require.Equal(t, tc.ua, v.Raw)
+	}
+}
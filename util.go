@@ -1,25 +1,74 @@
 package httpbin
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 )
 
 func writeJSON(w io.Writer, v interface{}) error {
+	if ks, ok := w.(interface {
+		jsonKeyStyle() JSONKeyStyle
+	}); ok {
+		return writeJSONKeyStyle(w, v, ks.jsonKeyStyle())
+	}
 	e := json.NewEncoder(w)
 	e.SetIndent("", "  ")
 	return errors.Wrap(e.Encode(v), "failed to encode JSON")
 }
 
+func writeJSONKeyStyle(w io.Writer, v interface{}, style JSONKeyStyle) error {
+	b, err := marshalJSONKeyStyle(v, style)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode JSON")
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return errors.Wrap(err, "failed to encode JSON")
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(w)
+	return err
+}
+
 func writeErrorJSON(w http.ResponseWriter, err error) {
-	w.WriteHeader(http.StatusInternalServerError)
+	writeErrorJSONStatus(w, http.StatusInternalServerError, err)
+}
+
+func writeErrorJSONStatus(w http.ResponseWriter, code int, err error) {
+	w.WriteHeader(code)
 	_ = writeJSON(w, errorResponse{errObj{err.Error()}}) // ignore error, can't do anything
 }
 
+// sleepContext sleeps for d or until the request is canceled, and reports
+// whether the full duration elapsed.
+func sleepContext(r *http.Request, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
 func getHeaders(r *http.Request) map[string]string {
 	hdr := make(map[string]string, len(r.Header))
 	for k, v := range r.Header {
@@ -28,6 +77,19 @@ func getHeaders(r *http.Request) map[string]string {
 	return hdr
 }
 
+// filterHeaders returns the subset of hdr with the given names, which are
+// matched case-insensitively.
+func filterHeaders(hdr map[string]string, names []string) map[string]string {
+	m := make(map[string]string, len(names))
+	for _, n := range names {
+		k := http.CanonicalHeaderKey(strings.TrimSpace(n))
+		if v, ok := hdr[k]; ok {
+			m[k] = v
+		}
+	}
+	return m
+}
+
 func getCookies(cs []*http.Cookie) map[string]string {
 	m := make(map[string]string, len(cs))
 	for _, v := range cs {
@@ -48,3 +110,365 @@ func flattenValues(uv url.Values) map[string]interface{} {
 	}
 	return m
 }
+
+// parseFormData parses data, the body of r, as a url-encoded or multipart
+// form according to the Content-Type of r, and returns nil for other content
+// types. Uploaded files are returned by field name with their content as
+// text, or as a base64 data URL if it is not valid UTF-8.
+func parseFormData(r *http.Request, data []byte) (url.Values, map[string]string, error) {
+	mt, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mt {
+	case "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(data))
+		return form, nil, errors.Wrap(err, "failed to parse form")
+	case "multipart/form-data":
+		f, err := multipart.NewReader(bytes.NewReader(data), params["boundary"]).ReadForm(MaxBodySize)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to parse multipart form")
+		}
+		defer f.RemoveAll()
+		files := make(map[string]string, len(f.File))
+		for k, fhs := range f.File {
+			fr, err := fhs[0].Open()
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "failed to open form file")
+			}
+			b, err := ioutil.ReadAll(fr)
+			fr.Close()
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "failed to read form file")
+			}
+			if utf8.Valid(b) {
+				files[k] = string(b)
+				continue
+			}
+			ct := fhs[0].Header.Get("Content-Type")
+			if ct == "" {
+				ct = "application/octet-stream"
+			}
+			files[k] = "data:" + ct + ";base64," + base64.StdEncoding.EncodeToString(b)
+		}
+		return url.Values(f.Value), files, nil
+	}
+	return nil, nil, nil
+}
+
+// mergeValues flattens the union of a and b, where a key present in b replaces
+// all values of that key in a.
+func mergeValues(a, b url.Values) map[string]interface{} {
+	uv := make(url.Values, len(a)+len(b))
+	for k, v := range a {
+		uv[k] = v
+	}
+	for k, v := range b {
+		uv[k] = v
+	}
+	return flattenValues(uv)
+}
+
+// etagFor returns a quoted strong ETag derived from the SHA-256 of b.
+func etagFor(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:])[:16] + `"`
+}
+
+// parseETag parses an entity-tag as defined by RFC 7232, section 2.3, and
+// returns whether it is weak and its opaque tag without the quotes. The
+// weakness indicator may be lowercase and the quotes missing, as some servers
+// send them that way, in which case canonical reports false.
+func parseETag(s string) (weak bool, opaque string, canonical bool, err error) {
+	canonical = true
+	switch {
+	case strings.HasPrefix(s, "W/"):
+		weak, s = true, s[2:]
+	case strings.HasPrefix(s, "w/"):
+		weak, s, canonical = true, s[2:], false
+	}
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	} else if s == "" || strings.Contains(s, `"`) {
+		return false, "", false, errors.New("entity-tag must be a quoted string")
+	} else {
+		canonical = false
+	}
+	for i := 0; i < len(s); i++ {
+		// etagc = %x21 / %x23-7E / obs-text
+		if c := s[i]; c <= ' ' || c == '"' || c == 0x7f {
+			return false, "", false, errors.Errorf("entity-tag contains invalid character %q", c)
+		}
+	}
+	return weak, s, canonical, nil
+}
+
+// parseByteRange parses a single "bytes=" range from a Range header value
+// against a resource of the given size and returns the inclusive start and
+// end offsets. Multiple ranges are not supported.
+func parseByteRange(s string, size int) (int, int, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(s, prefix) {
+		return 0, 0, errors.New("unsupported range unit")
+	}
+	spec := strings.TrimSpace(s[len(prefix):])
+	if strings.Contains(spec, ",") {
+		return 0, 0, errors.New("multiple ranges not supported")
+	}
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return 0, 0, errors.New("malformed range")
+	}
+	startStr, endStr := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+
+	if startStr == "" { // suffix range: last n bytes
+		n, err := strconv.Atoi(endStr)
+		if err != nil || n <= 0 {
+			return 0, 0, errors.New("malformed suffix range")
+		}
+		if n > size {
+			n = size
+		}
+		if n == 0 {
+			return 0, 0, errors.New("range not satisfiable")
+		}
+		return size - n, size - 1, nil
+	}
+
+	start, err := strconv.Atoi(startStr)
+	if err != nil || start < 0 {
+		return 0, 0, errors.New("malformed range start")
+	}
+	if start >= size {
+		return 0, 0, errors.New("range not satisfiable")
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.Atoi(endStr)
+		if err != nil || end < start {
+			return 0, 0, errors.New("malformed range end")
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, nil
+}
+
+// negotiateType returns the media type among offers that is most preferred
+// by the Accept header of r, weighing each offer by the most specific media
+// range matching it. Ties and requests without an Accept header go to the
+// earliest offer. If no offer is acceptable, the earliest one not refused
+// with q=0 is returned, or "" if all of them are refused. Malformed media
+// ranges are ignored.
+func negotiateType(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0]
+	}
+	var ranges []acceptEntry
+	for _, part := range strings.Split(accept, ",") {
+		if e, err := parseAcceptRange(part); err == nil {
+			ranges = append(ranges, e)
+		}
+	}
+	best, bestQ, fallback := "", 0.0, ""
+	for _, offer := range offers {
+		typ := offer[:strings.Index(offer, "/")]
+		q, specificity := -1.0, -1 // no matching range yet
+		for _, e := range ranges {
+			s := -1
+			switch {
+			case e.Type == typ && e.Subtype == offer[len(typ)+1:]:
+				s = 2
+			case e.Type == typ && e.Subtype == "*":
+				s = 1
+			case e.Type == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = e.Q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+		if q != 0 && fallback == "" {
+			fallback = offer
+		}
+	}
+	if best == "" {
+		return fallback
+	}
+	return best
+}
+
+var (
+	// acceptWeight finds the weight parameter of a media range, which
+	// separates the media type parameters from the accept extensions.
+	acceptWeight = regexp.MustCompile(`(?i);[ \t]*q=`)
+
+	// qvalue matches the weight syntax of RFC 7231, section 5.3.1.
+	qvalue = regexp.MustCompile(`^(?:0(?:\.[0-9]{0,3})?|1(?:\.0{0,3})?)$`)
+)
+
+// parseAcceptRange parses a single media range of an Accept header, as in
+// "text/html;level=1;q=0.5;ext=x".
+func parseAcceptRange(s string) (acceptEntry, error) {
+	e := acceptEntry{Q: 1}
+	s = strings.TrimSpace(s)
+	var ext string
+	if loc := acceptWeight.FindStringIndex(s); loc != nil {
+		s, ext = s[:loc[0]], s[loc[1]-2:]
+	}
+	mt, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		return e, errors.Wrap(err, "invalid media type")
+	}
+	i := strings.IndexByte(mt, '/')
+	if i < 0 {
+		return e, errors.Errorf("media type %q has no subtype", mt)
+	}
+	e.Type, e.Subtype = mt[:i], mt[i+1:]
+	if e.Type == "*" && e.Subtype != "*" {
+		return e, errors.Errorf("media range %q has a wildcard type but not subtype", mt)
+	}
+	if len(params) > 0 {
+		e.Params = params
+	}
+	if ext == "" {
+		return e, nil
+	}
+	_, params, err = mime.ParseMediaType("x/x;" + ext)
+	if err != nil {
+		return e, errors.Wrap(err, "invalid accept extension")
+	}
+	if !qvalue.MatchString(params["q"]) {
+		return e, errors.Errorf("invalid weight %q", params["q"])
+	}
+	e.Q, _ = strconv.ParseFloat(params["q"], 64)
+	delete(params, "q")
+	if len(params) > 0 {
+		e.Extensions = params
+	}
+	return e, nil
+}
+
+// parseAccept parses the media ranges of an Accept header value and sorts them
+// by preference: by weight, then more specific ranges first. The error names
+// the first malformed range. Empty list elements are skipped.
+func parseAccept(h string) ([]acceptEntry, error) {
+	entries := []acceptEntry{}
+	for _, part := range strings.Split(h, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		e, err := parseAcceptRange(part)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %q", strings.TrimSpace(part))
+		}
+		entries = append(entries, e)
+	}
+	specificity := func(e acceptEntry) int {
+		switch {
+		case e.Type == "*":
+			return 0
+		case e.Subtype == "*":
+			return 1
+		}
+		return 2 + len(e.Params)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Q != entries[j].Q {
+			return entries[i].Q > entries[j].Q
+		}
+		return specificity(entries[i]) > specificity(entries[j])
+	})
+	return entries, nil
+}
+
+// baseURL returns the scheme and host the client addressed the request to, as
+// in "https://example.com:8443", with the scheme from requestScheme.
+func baseURL(r *http.Request) string {
+	return requestScheme(r) + "://" + r.Host
+}
+
+// requestURL returns the absolute URL of the request, with the request target
+// exactly as received, e.g. without re-encoding the query string.
+func requestURL(r *http.Request) string {
+	if r.URL.IsAbs() { // absolute-form, as sent to proxies
+		return r.RequestURI
+	}
+	return baseURL(r) + r.RequestURI
+}
+
+// requestScheme returns the scheme the client used for the request, so that
+// it is correct behind a TLS-terminating proxy: the proto of the Forwarded
+// header if present, else the X-Forwarded-Proto header, else whether the
+// request came over TLS. Forwarded schemes other than http and https are
+// ignored, so that links are never built with them.
+func requestScheme(r *http.Request) string {
+	for _, v := range []string{forwardedScheme(r), forwardedProtoScheme(r)} {
+		if v == "http" || v == "https" {
+			return v
+		}
+	}
+	return tlsScheme(r)
+}
+
+// tlsScheme returns "https" if r was received over TLS, "http" otherwise.
+func tlsScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// forwardedProtoScheme returns the first X-Forwarded-Proto value of r, in
+// lower case, or "" if there is none.
+func forwardedProtoScheme(r *http.Request) string {
+	v := r.Header.Get("X-Forwarded-Proto")
+	return strings.ToLower(strings.TrimSpace(strings.Split(v, ",")[0]))
+}
+
+// forwardedScheme returns the proto parameter of the first element of the
+// RFC 7239 Forwarded header of r, in lower case, or "" if there is none.
+func forwardedScheme(r *http.Request) string {
+	v := r.Header.Get("Forwarded")
+	for _, pair := range strings.Split(strings.Split(v, ",")[0], ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), "proto") {
+			return strings.ToLower(strings.Trim(strings.TrimSpace(kv[1]), `"`))
+		}
+	}
+	return ""
+}
+
+// inertContentType reports whether browsers only display or download content
+// of media type ct, so that it cannot run script whatever the content is. It
+// is an allowlist, as HTML, SVG, JavaScript and the XML types can all run
+// script.
+func inertContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	switch mt {
+	case "text/plain", "text/csv", "application/json", "application/octet-stream":
+		return true
+	}
+	return strings.HasPrefix(mt, "application/") && strings.HasSuffix(mt, "+json")
+}
+
+// saveData reports whether the client asked for reduced data usage with the
+// Save-Data client hint. As the response depends on it, Vary: Save-Data is
+// added either way.
+func saveData(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "Save-Data")
+	return strings.EqualFold(strings.TrimSpace(r.Header.Get("Save-Data")), "on")
+}
+
+// serveContent writes b with the ETag of etagFor and, unless modtime is zero,
+// a Last-Modified header, answering conditional requests on either with 304
+// Not Modified as http.ServeContent does.
+func serveContent(w http.ResponseWriter, r *http.Request, modtime time.Time, b []byte) {
+	w.Header().Set("ETag", etagFor(b))
+	http.ServeContent(w, r, "", modtime, bytes.NewReader(b))
+}
@@ -0,0 +1,109 @@
+package httpbin
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEtagFor(t *testing.T) {
+	a := etagFor([]byte("hello"))
+	require.Regexp(t, `^"[0-9a-f]{16}"$`, a)
+	require.Equal(t, a, etagFor([]byte("hello")))
+	require.NotEqual(t, a, etagFor([]byte("hello!")))
+	require.NotEqual(t, a, etagFor(nil))
+}
+
+func TestParseETag(t *testing.T) {
+	cases := []struct {
+		in        string
+		weak      bool
+		opaque    string
+		canonical bool
+	}{
+		{`"abc"`, false, "abc", true},
+		{`W/"abc"`, true, "abc", true},
+		{`""`, false, "", true},
+		{`w/"abc"`, true, "abc", false},
+		{`abc`, false, "abc", false},
+		{`W/abc`, true, "abc", false},
+		{"\"caf\xc3\xa9\"", false, "caf\xc3\xa9", true}, // obs-text
+	}
+	for _, c := range cases {
+		weak, opaque, canonical, err := parseETag(c.in)
+		require.Nil(t, err, c.in)
+		require.Equal(t, c.weak, weak, c.in)
+		require.Equal(t, c.opaque, opaque, c.in)
+		require.Equal(t, c.canonical, canonical, c.in)
+	}
+
+	for _, in := range []string{``, `W/`, `"abc`, `ab"c`, `"a"b"`, `"a b"`, "\"a\x7f\"", `W/W/"a"`} {
+		_, _, _, err := parseETag(in)
+		require.NotNil(t, err, in)
+	}
+}
+
+func TestNegotiateType(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                                   "application/json",
+		"*/*":                                "application/json",
+		"text/plain":                         "text/plain",
+		"text/*":                             "text/plain",
+		"text/plain;q=0.5, application/json": "application/json",
+		"text/plain, application/json;q=0.9": "text/plain",
+		"image/png":                          "application/json",
+		"application/json;q=0, */*":          "text/plain",
+		"*/*;q=0.5, text/*;q=0, text/plain":  "text/plain",
+		"text/*;q=0, */*;q=0.5":              "application/json",
+		"application/json;q=0":               "text/plain",
+		"application/json;q=0, text/*;q=0":   "",
+	} {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		require.Equal(t, want, negotiateType(r, "application/json", "text/plain"), accept)
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	entries, err := parseAccept(`text/*, text/html;level=1;q=0.5;ext="a b", , */*;q=0.1, text/html, application/json;Q=1.000`)
+	require.Nil(t, err)
+	require.Equal(t, []acceptEntry{
+		{Type: "text", Subtype: "html", Q: 1},
+		{Type: "application", Subtype: "json", Q: 1},
+		{Type: "text", Subtype: "*", Q: 1},
+		{Type: "text", Subtype: "html", Q: 0.5, Params: map[string]string{"level": "1"},
+			Extensions: map[string]string{"ext": "a b"}},
+		{Type: "*", Subtype: "*", Q: 0.1},
+	}, entries)
+
+	for _, bad := range []string{"text", "*/html", "text/html;q=2", "text/html;q=0.1234", "text/html;q=0.5;=x", "te xt/html"} {
+		_, err := parseAccept("text/plain, " + bad)
+		require.NotNil(t, err, bad)
+		require.Contains(t, err.Error(), bad)
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com:8080/self", nil)
+	require.Equal(t, "http://example.com:8080", baseURL(r))
+
+	r.TLS = &tls.ConnectionState{}
+	require.Equal(t, "https://example.com:8080", baseURL(r))
+
+	r.TLS = nil
+	r.Header.Set("X-Forwarded-Proto", "HTTPS, http")
+	require.Equal(t, "https://example.com:8080", baseURL(r))
+
+	r.Header.Set("Forwarded", `for=192.0.2.60;Proto="HTTP";by=203.0.113.43, proto=https`)
+	require.Equal(t, "http://example.com:8080", baseURL(r))
+
+	// schemes other than http and https are ignored
+	r.Header.Set("Forwarded", "proto=javascript")
+	require.Equal(t, "https://example.com:8080", baseURL(r))
+	r.Header.Set("X-Forwarded-Proto", "javascript")
+	require.Equal(t, "http://example.com:8080", baseURL(r))
+	r.TLS = &tls.ConnectionState{}
+	require.Equal(t, "https://example.com:8080", baseURL(r))
+}
@@ -0,0 +1,53 @@
+package httpbin
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// marshalYAML encodes the slideshow as a YAML block mapping keyed like its
+// JSON encoding, leaving out empty items the same way.
+func (v slideshowResponse) marshalYAML() []byte {
+	s := v.Slideshow
+	var b bytes.Buffer
+	b.WriteString("slideshow:\n")
+	b.WriteString("  title: " + yamlString(s.Title) + "\n")
+	b.WriteString("  date: " + yamlString(s.Date) + "\n")
+	b.WriteString("  author: " + yamlString(s.Author) + "\n")
+	if len(s.Slides) == 0 {
+		b.WriteString("  slides: []\n")
+		return b.Bytes()
+	}
+	b.WriteString("  slides:\n")
+	for _, sl := range s.Slides {
+		b.WriteString("    - type: " + yamlString(sl.Type) + "\n")
+		b.WriteString("      title: " + yamlString(sl.Title) + "\n")
+		if len(sl.Items) > 0 {
+			b.WriteString("      items:\n")
+			for _, it := range sl.Items {
+				b.WriteString("        - " + yamlString(it) + "\n")
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+// yamlString returns s as a plain scalar if YAML reads it back as the same
+// string, and double quoted otherwise.
+func yamlString(s string) string {
+	if s == "" || s != strings.TrimSpace(s) ||
+		strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") ||
+		strings.HasSuffix(s, ":") || !strconv.CanBackquote(s) {
+		return strconv.Quote(s)
+	}
+	switch strings.ToLower(s) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	return s
+}
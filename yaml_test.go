@@ -0,0 +1,53 @@
+package httpbin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlideshowMarshalYAML(t *testing.T) {
+	require.Equal(t, `slideshow:
+  title: Sample Slide Show
+  date: Date of publication
+  author: Yours Truly
+  slides:
+    - type: all
+      title: Wake up to WonderWidgets!
+    - type: all
+      title: Overview
+      items:
+        - Why <em>WonderWidgets</em> are great
+        - ""
+        - Who <em>buys</em> WonderWidgets
+`, string(sampleSlideshow.marshalYAML()))
+
+	require.Equal(t, `slideshow:
+  title: ""
+  date: ""
+  author: ""
+  slides: []
+`, string(slideshowResponse{}.marshalYAML()))
+}
+
+func TestYAMLString(t *testing.T) {
+	for in, out := range map[string]string{
+		"Yours Truly":    "Yours Truly",
+		"<em>a</em> b":   "<em>a</em> b",
+		"a-b: c":         `"a-b: c"`,
+		"":               `""`,
+		" a":             `" a"`,
+		"- a":            `"- a"`,
+		"a #b":           `"a #b"`,
+		"a:":             `"a:"`,
+		"true":           `"true"`,
+		"No":             `"No"`,
+		"~":              `"~"`,
+		"1.5":            `"1.5"`,
+		"a\nb":           `"a\nb"`,
+		`"quoted"`:       `"\"quoted\""`,
+		"http://a.b/c#d": "http://a.b/c#d",
+	} {
+		require.Equal(t, out, yamlString(in), "%q", in)
+	}
+}